@@ -3,130 +3,583 @@ package anathema
 import (
 	"fmt"
 	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 
 	"dmitri.shuralyov.com/go/generated"
 	"golang.org/x/tools/go/analysis"
 )
 
 func Analysis(c *Configuration) *analysis.Analyzer {
+	opts := &options{}
 	a := &analysis.Analyzer{
-		Name: "anathema",
-		Doc:  "Flags the use of symbols that have been marked as forbidden.",
-		Run:  runner(c),
+		Name:       "anathema",
+		Doc:        "Flags the use of symbols that have been marked as forbidden.",
+		Run:        runner(c, opts),
+		ResultType: reflect.TypeOf((*Result)(nil)),
 	}
-	a.Flags.StringVar(&configPath, "config", "", "path to the configuration file")
+	a.Flags.StringVar(&configPath, "config", "", "path to the configuration file, instead of the "+configFileName+" files of each package")
+	a.Flags.StringVar(&opts.baseline, "baseline", "", "path to a baseline file of tolerated findings")
+	a.Flags.StringVar(
+		&opts.severities,
+		"severity",
+		"",
+		"comma-separated overrides of severities, e.g 'warning=error' or 'os.Exit=info' for a single rule",
+	)
+	a.Flags.StringVar(&opts.enable, "enable", "", "comma-separated IDs or globs of the only rules to apply")
+	a.Flags.StringVar(&opts.disable, "disable", "", "comma-separated IDs or globs of rules not to apply")
 	return a
 }
 
-func runner(config *Configuration) func(pass *analysis.Pass) (interface{}, error) {
+// options holds the flags of an analyzer.
+type options struct {
+	baseline   string
+	severities string
+	enable     string
+	disable    string
+}
+
+func runner(config *Configuration, opts *options) func(pass *analysis.Pass) (interface{}, error) {
+	var configs configCache
+	var modules moduleCache
+	var loadConfig, loadBaseline sync.Once
+	var loaded *Configuration
+	var configErr error
+	var tolerated *allowance
+	var baselineErr error
+
 	return func(pass *analysis.Pass) (interface{}, error) {
-		config = getConfig(config)
-		c, err := config.validate()
+		cfg := config
+		if cfg == nil && configPath != "" {
+			loadConfig.Do(func() { loaded, configErr = LoadConfiguration(configPath) })
+			if configErr != nil {
+				return nil, configErr
+			}
+			cfg = loaded
+		} else if cfg == nil {
+			var err error
+			if cfg, err = configs.discover(pass); err != nil {
+				return nil, err
+			}
+		}
+
+		c, err := cfg.validate()
+		if err != nil {
+			return nil, err
+		}
+		c.now = cfg.now()
+		c.mainModule = modules.mainModule(pass)
+		if c.dependsOnModule() {
+			if err = checkInconsistencies(c); err != nil {
+				return nil, err
+			}
+		}
+		if c.selected, err = parseRuleSelection(opts.enable, opts.disable); err != nil {
+			return nil, err
+		}
+
+		loadBaseline.Do(func() {
+			var baseline *Baseline
+			if opts.baseline != "" {
+				baseline, baselineErr = LoadBaseline(opts.baseline)
+			}
+			tolerated = newAllowance(baseline)
+		})
+		if baselineErr != nil {
+			return nil, baselineErr
+		}
+
+		severities, err := parseSeverities(opts.severities)
 		if err != nil {
 			return nil, err
 		}
 
+		var files []*ast.File
 		for _, file := range pass.Files {
 			path := pass.Fset.File(file.Package).Name()
 			isGenerated, err := generated.ParseFile(path)
 			if err != nil {
 				return nil, err
-			} else if isGenerated {
-				continue
+			} else if !isGenerated {
+				files = append(files, file)
 			}
+		}
 
-			checkImports(pass, c, file)
-			checkSymbols(pass, c, file)
+		r := newReporter(pass, files, tolerated, severities)
+		for _, file := range files {
+			imports := newImportEditor(pass, file)
+			checkImports(pass, c, r, file, imports)
+			checkSymbols(pass, c, r, file, imports)
+			if c.indirectTypes {
+				checkTypes(pass, c, r, file)
+			}
 		}
+		r.reportDirectives()
 
-		return nil, nil
+		return r.result, nil
 	}
 }
 
-func checkImports(pass *analysis.Pass, c *configuration, file *ast.File) {
+func checkImports(pass *analysis.Pass, c *configuration, r *reporter, file *ast.File, imports *importEditor) {
 	for _, imp := range file.Imports {
-		path := strings.Trim(imp.Path.Value, `"`)
-		repl, ok := c.packages[path]
-		if c.whitelistPackages {
-			if !ok {
-				pass.ReportRangef(imp, "%s should not be used", path)
-			}
-			continue
-		} else if !ok {
+		path := importPath(imp)
+		t, denied := c.packageTargetIn(importerPath(pass.Pkg), path)
+		if !denied || c.allowsSymbolsOf(importerPath(pass.Pkg), path) {
 			continue
 		}
 
-		d := analysis.Diagnostic{
-			Pos: imp.Pos(),
-			End: imp.End(),
+		d := finding{
+			Diagnostic: analysis.Diagnostic{
+				Pos:      imp.Pos(),
+				End:      imp.End(),
+				Category: t.severity,
+			},
+			subject: path,
+			rule:    t.id,
+		}
+		d.Message = message(path, importerPath(pass.Pkg), t, t.replacement)
+		if r.suppressed(d) {
+			continue
 		}
-		if repl == "" {
-			d.Message = fmt.Sprintf("%s should not be used", path)
-		} else {
-			d.Message = fmt.Sprintf("%s should be replaced with %s", path, repl)
+		if t.replacement != "" {
 			d.SuggestedFixes = []analysis.SuggestedFix{
 				{
-					Message: fmt.Sprintf("Replace import of %s with %s", path, repl),
-					TextEdits: []analysis.TextEdit{
-						{
-							Pos:     imp.Pos(),
-							End:     imp.End(),
-							NewText: []byte(fmt.Sprintf(`"%s"`, repl)),
-						},
-					},
+					Message:   fmt.Sprintf("Replace import of %s with %s", path, t.replacement),
+					TextEdits: importFix(pass, file, imports, imp, t),
 				},
 			}
 		}
-		pass.Report(d)
+		r.report(d)
 	}
 }
 
-func checkSymbols(pass *analysis.Pass, c *configuration, file *ast.File) {
+// importFix returns the edits that replace the given import. Unless the import already has an
+// explicit name, the replacement package may be known under a different name than the original one.
+// Depending on the rule this is either solved by aliasing the new import to the original name or by
+// rewriting all selectors in the file that reference the import.
+func importFix(
+	pass *analysis.Pass,
+	file *ast.File,
+	imports *importEditor,
+	imp *ast.ImportSpec,
+	t target,
+) []analysis.TextEdit {
+	pathEdit := analysis.TextEdit{
+		Pos:     imp.Path.Pos(),
+		End:     imp.Path.End(),
+		NewText: []byte(strconv.Quote(t.replacement)),
+	}
+
+	pkgName := imports.importedName(imp)
+	if imp.Name != nil || pkgName == nil {
+		return []analysis.TextEdit{pathEdit}
+	}
+
+	name, known := packageName(pass, t.replacement)
+	if known && name == pkgName.Name() {
+		return []analysis.TextEdit{pathEdit}
+	}
+
+	if !t.rewriteSelectors {
+		return []analysis.TextEdit{{
+			Pos:     imp.Pos(),
+			End:     imp.End(),
+			NewText: []byte(fmt.Sprintf("%s %q", pkgName.Name(), t.replacement)),
+		}}
+	}
+
+	name, explicit := imports.newName(name, known)
+	newText := strconv.Quote(t.replacement)
+	if explicit {
+		newText = name + " " + newText
+	}
+	edits := []analysis.TextEdit{{Pos: imp.Pos(), End: imp.End(), NewText: []byte(newText)}}
 	ast.Inspect(file, func(n ast.Node) bool {
-		se, ok := n.(*ast.SelectorExpr)
-		if !ok {
-			return true
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == pkgName {
+			edits = append(edits, analysis.TextEdit{Pos: id.Pos(), End: id.End(), NewText: []byte(name)})
 		}
+		return true
+	})
+	return edits
+}
 
-		if _, ok = se.X.(*ast.Ident); !ok {
-			return true
+// symbolUse describes a reference to a symbol, either as part of a selector expression or through a
+// dot-import of the symbol's package.
+type symbolUse struct {
+	expr    ast.Expr       // Expression that references the symbol.
+	id      *ast.Ident     // Identifier that resolves to the symbol.
+	pkgName *types.PkgName // Imported package on which the symbol is selected, if any.
+	dot     bool           // Whether the symbol is referenced through a dot-import.
+}
+
+func checkSymbols(pass *analysis.Pass, c *configuration, r *reporter, file *ast.File, imports *importEditor) {
+	var findings []finding
+	var fixes []fixBuilder
+	calls := map[ast.Expr]*ast.CallExpr{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			calls[n.Fun] = n
+		case *ast.SelectorExpr:
+			if sel, ok := pass.TypesInfo.Selections[n]; ok {
+				if d, ok := checkSelection(pass, c, n, sel); ok && !r.suppressed(d) {
+					findings = append(findings, d)
+				}
+				return true
+			}
+			x, ok := n.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			pkgName, ok := pass.TypesInfo.Uses[x].(*types.PkgName)
+			if !ok {
+				// The selector is not qualified by a package but selects a field or method of a symbol,
+				// such as a dot-imported one, which is checked on its own.
+				return true
+			}
+			use := symbolUse{expr: n, id: n.Sel, pkgName: pkgName}
+			if d, fix, ok := checkSymbol(pass, c, r, calls, use); ok {
+				findings, fixes = append(findings, d), append(fixes, fix)
+			}
+			return false
+		case *ast.Ident:
+			// Identifiers that resolve to package-level objects of other packages can only originate
+			// from dot-imports as all other references are part of a selector expression.
+			obj := pass.TypesInfo.Uses[n]
+			if obj == nil || obj.Pkg() == nil || obj.Pkg() == pass.Pkg || obj.Parent() != obj.Pkg().Scope() {
+				return true
+			}
+			if d, fix, ok := checkSymbol(pass, c, r, calls, symbolUse{expr: n, id: n, dot: true}); ok {
+				findings, fixes = append(findings, d), append(fixes, fix)
+			}
 		}
+		return true
+	})
 
-		obj, ok := pass.TypesInfo.Uses[se.Sel]
-		if !ok {
-			return true
-		} else if obj.Pkg() == nil {
-			return true
+	// The fixes are first built on copies of the findings to learn which imports they remove, as the
+	// names of these imports are then available to the imports that the fixes add. Suppressed findings
+	// have been dropped before their fixes could require any import changes.
+	trial, scratch := append([]finding(nil), findings...), imports.clone()
+	for idx, fix := range fixes {
+		if fix != nil {
+			fix(scratch, &trial[idx])
 		}
+	}
+	for pkgName := range scratch.groups(trial) {
+		imports.release(pkgName)
+	}
+	for idx, fix := range fixes {
+		if fix != nil {
+			fix(imports, &findings[idx])
+		}
+	}
+	imports.attach(findings)
+	for _, d := range findings {
+		r.report(d)
+	}
+}
+
+// fixBuilder adds the suggested fix of a finding, reserving the names of the imports that it requires
+// with the given import editor.
+type fixBuilder func(imports *importEditor, d *finding)
+
+// checkSymbol returns the finding for the given use of a symbol, if any, unless it is suppressed,
+// together with the builder of its suggested fix. The fixes are only built once all findings of the
+// file are known so that the import editor only accounts for the uses that are actually rewritten.
+func checkSymbol(
+	pass *analysis.Pass,
+	c *configuration,
+	r *reporter,
+	calls map[ast.Expr]*ast.CallExpr,
+	use symbolUse,
+) (finding, fixBuilder, bool) {
+	obj, ok := pass.TypesInfo.Uses[use.id]
+	if !ok {
+		return finding{}, nil, false
+	} else if obj.Pkg() == nil {
+		return finding{}, nil, false
+	}
 
-		path := obj.Pkg().Path()
-		if idx := strings.LastIndex(obj.Pkg().Path(), "vendor/"); idx > 0 {
-			path = path[idx+7:]
+	path := vendorlessPath(obj.Pkg().Path())
+	symbol := fmt.Sprintf("%s.%s", path, obj.Name())
+	d := finding{
+		Diagnostic: analysis.Diagnostic{
+			Pos: use.expr.Pos(),
+			End: use.expr.End(),
+		},
+		subject: symbol,
+	}
+
+	t, denied, matched := c.symbolTargetIn(importerPath(pass.Pkg), path, func(pkg string) string { return pkg + "." + obj.Name() })
+	d.rule, d.Category = t.id, t.severity
+	if !matched && (use.dot || c.allowsSymbolsOf(importerPath(pass.Pkg), path)) {
+		// The package rules take precedence over symbol allow-lists for symbols that are not listed.
+		if pd, ok := checkPackageSymbol(c, d, importerPath(pass.Pkg), path, symbol, obj.Name()); ok || !denied {
+			return pd, nil, ok && !r.suppressed(pd)
 		}
+	}
+	if !denied {
+		return finding{}, nil, false
+	}
 
-		symbol := fmt.Sprintf("%s.%s", path, obj.Name())
-		repl, ok := c.symbols[symbol]
-		if c.whitelistSymbols {
-			if !ok {
-				pass.ReportRangef(se, "%s should not be used", symbol)
-			}
-			return true
-		} else if !ok {
-			return true
+	switch {
+	case t.template != nil:
+		d.Message = message(symbol, importerPath(pass.Pkg), t, t.template.raw)
+	case t.replacement == "":
+		d.Message = message(symbol, importerPath(pass.Pkg), t, "")
+	default:
+		d.Message = message(symbol, importerPath(pass.Pkg), t, t.replacement)
+	}
+	if r.suppressed(d) {
+		return finding{}, nil, false
+	}
+
+	switch {
+	case t.template != nil:
+		return d, func(imports *importEditor, d *finding) {
+			templateFix(pass, imports, d, use, calls[use.expr], path, t.template)
+		}, true
+	case t.replacement != "":
+		return d, func(imports *importEditor, d *finding) {
+			symbolFix(pass, c, imports, d, use, path, t.replacement)
+		}, true
+	}
+	return d, nil, true
+}
+
+// checkSelection applies the symbol rules that target methods and fields to the given selection. The
+// type that declares the selected method or field is resolved so that promoted methods and fields as
+// well as method expressions and calls through interfaces are all taken into account.
+func checkSelection(
+	pass *analysis.Pass,
+	c *configuration,
+	se *ast.SelectorExpr,
+	sel *types.Selection,
+) (finding, bool) {
+	named := declaringType(sel)
+	if named == nil || named.Obj().Pkg() == nil {
+		return finding{}, false
+	}
+
+	format := func(pkg string) string {
+		return fmt.Sprintf("(%s.%s).%s", pkg, named.Obj().Name(), sel.Obj().Name())
+	}
+	symbol := format(vendorlessPath(named.Obj().Pkg().Path()))
+	// Methods and fields are only denied by the rules that list them.
+	t, denied, matched := c.symbolTargetIn(importerPath(pass.Pkg), vendorlessPath(named.Obj().Pkg().Path()), format)
+	if !matched || !denied {
+		return finding{}, false
+	}
+
+	d := finding{
+		Diagnostic: analysis.Diagnostic{
+			Pos:      se.Pos(),
+			End:      se.End(),
+			Category: t.severity,
+		},
+		subject: symbol,
+		rule:    t.id,
+	}
+	if t.replacement == "" {
+		d.Message = message(symbol, importerPath(pass.Pkg), t, "")
+	} else {
+		d.Message = message(symbol, importerPath(pass.Pkg), t, t.replacement)
+		targetName := t.replacement[strings.LastIndex(t.replacement, ".")+1:]
+		d.SuggestedFixes = []analysis.SuggestedFix{
+			{
+				Message: fmt.Sprintf("Replace %s with %s", symbol, t.replacement),
+				TextEdits: []analysis.TextEdit{
+					{Pos: se.Sel.Pos(), End: se.Sel.End(), NewText: []byte(targetName)},
+				},
+			},
 		}
+	}
+	return d, true
+}
+
+// declaringType returns the named type that declares the selected method or field.
+func declaringType(sel *types.Selection) *types.Named {
+	if sig, ok := sel.Obj().Type().(*types.Signature); ok && sig.Recv() != nil {
+		return namedType(sig.Recv().Type())
+	}
 
-		d := analysis.Diagnostic{
-			Pos: se.Pos(),
-			End: se.End(),
+	t := sel.Recv()
+	path := sel.Index()
+	for _, idx := range path[:len(path)-1] {
+		t = unalias(t)
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
 		}
-		if repl == "" {
-			d.Message = fmt.Sprintf("%s should not be used", symbol)
-		} else {
-			d.Message = fmt.Sprintf("%s should be replaced with %s", symbol, repl)
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return nil
 		}
-		pass.Report(d)
+		t = st.Field(idx).Type()
+	}
+	return namedType(t)
+}
 
-		return true
+func namedType(t types.Type) *types.Named {
+	if p, ok := unalias(t).(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := unalias(t).(*types.Named)
+	return named
+}
+
+func vendorlessPath(path string) string {
+	if idx := strings.LastIndex(path, "vendor/"); idx > 0 {
+		return path[idx+7:]
+	}
+	return path
+}
+
+// importerPath returns the path of the analysed package against which the importer scopes of rules are
+// matched. External test packages are matched as the package that they test.
+func importerPath(pkg *types.Package) string {
+	return strings.TrimSuffix(vendorlessPath(pkg.Path()), "_test")
+}
+
+// checkPackageSymbol applies the package rules to a symbol that no symbol rule matches. This is the case
+// for symbols that are referenced through a dot-import, which contrary to selectors do not reveal the
+// package they originate from and are flagged in addition to the import itself, and for the symbols of
+// a denied package of which other symbols are allowed, whose import is not flagged.
+func checkPackageSymbol(
+	c *configuration,
+	d finding,
+	importer string,
+	path string,
+	symbol string,
+	name string,
+) (finding, bool) {
+	t, denied := c.packageTargetIn(importer, path)
+	d.rule, d.Category = t.id, t.severity
+	switch {
+	case !denied:
+		return finding{}, false
+	case t.replacement == "":
+		d.Message = message(symbol, importer, t, "")
+	default:
+		d.Message = message(symbol, importer, t, t.replacement+"."+name)
+	}
+	return d, true
+}
+
+// message describes the finding for a subject that is forbidden by the rule of the given target and
+// its replacement, if any, including the date from which the rule will be enforced. The documentation
+// URL of the rule is part of the message as diagnostics have no field of their own for it.
+func message(subject string, importer string, t target, replacement string) string {
+	var msg string
+	switch {
+	case t.message != "" && t.forbiddenFrom.IsZero():
+		msg = expandMessage(t.message, subject, replacement, importer)
+	case t.message != "":
+		msg = fmt.Sprintf("%s (forbidden from %s)", expandMessage(t.message, subject, replacement, importer), t.forbiddenFrom.Format(dateLayout))
+	case t.forbiddenFrom.IsZero() && replacement == "":
+		msg = fmt.Sprintf("%s should not be used", subject)
+	case t.forbiddenFrom.IsZero():
+		msg = fmt.Sprintf("%s should be replaced with %s", subject, replacement)
+	case replacement == "":
+		msg = fmt.Sprintf("%s will be forbidden from %s", subject, t.forbiddenFrom.Format(dateLayout))
+	default:
+		msg = fmt.Sprintf("%s will be forbidden from %s; replace with %s", subject, t.forbiddenFrom.Format(dateLayout), replacement)
+	}
+	return withURL(msg, t)
+}
+
+func withURL(msg string, t target) string {
+	if t.url == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (see %s)", msg, t.url)
+}
+
+// symbolFix adds the fix that rewrites the given symbol reference so that it references the
+// replacement symbol instead. Any import changes that this requires are handled by the file's import
+// editor.
+func symbolFix(
+	pass *analysis.Pass,
+	c *configuration,
+	imports *importEditor,
+	d *finding,
+	use symbolUse,
+	sourcePkg string,
+	repl string,
+) {
+	if use.pkgName == nil && !use.dot {
+		return
+	}
+
+	idx := strings.LastIndex(repl, ".")
+	targetPkg, targetName := repl[:idx], repl[idx+1:]
+
+	var edit analysis.TextEdit
+	if pt, _ := c.packageTargetIn(importerPath(pass.Pkg), sourcePkg); targetPkg == sourcePkg || targetPkg == pt.replacement {
+		// The package itself remains available, or its import is already being replaced by the
+		// package rules. Only the name of the referenced symbol needs to change.
+		if targetName == use.id.Name {
+			return
+		}
+		edit = analysis.TextEdit{Pos: use.id.Pos(), End: use.id.End(), NewText: []byte(targetName)}
+	} else {
+		newText := targetName
+		if name := imports.localName(targetPkg); name != "" {
+			newText = name + "." + targetName
+			d.requires = append(d.requires, targetPkg)
+		}
+		d.rewrites = use.pkgName
+		edit = analysis.TextEdit{Pos: use.expr.Pos(), End: use.expr.End(), NewText: []byte(newText)}
+	}
+
+	d.SuggestedFixes = []analysis.SuggestedFix{
+		{
+			Message:   fmt.Sprintf("Replace %s with %s", d.subject, repl),
+			TextEdits: []analysis.TextEdit{edit},
+		},
+	}
+}
+
+// templateFix adds the fix that rewrites a call to a symbol based on the template of its rule. If the
+// selector is not called or if the call does not match the template no fix is added.
+func templateFix(
+	pass *analysis.Pass,
+	imports *importEditor,
+	d *finding,
+	use symbolUse,
+	call *ast.CallExpr,
+	sourcePkg string,
+	tmpl *callTemplate,
+) {
+	if call == nil || (use.pkgName == nil && !use.dot) {
+		return
+	}
+
+	var qualified bool
+	newText, ok := tmpl.render(pass.Fset, call, func() string {
+		qualified = true
+		name := imports.localName(tmpl.pkg)
+		if name != "" {
+			d.requires = append(d.requires, tmpl.pkg)
+		}
+		return name
 	})
+	if !ok {
+		return
+	}
+
+	if !qualified || tmpl.pkg != sourcePkg {
+		d.rewrites = use.pkgName
+	}
+
+	d.SuggestedFixes = []analysis.SuggestedFix{
+		{
+			Message: fmt.Sprintf("Replace call to %s with %s", d.subject, tmpl.raw),
+			TextEdits: []analysis.TextEdit{
+				{Pos: call.Pos(), End: call.End(), NewText: []byte(newText)},
+			},
+		},
+	}
 }
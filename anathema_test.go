@@ -1,8 +1,12 @@
 package anathema
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/tools/go/analysis/analysistest"
 )
 
@@ -52,3 +56,430 @@ func TestWhitelist(t *testing.T) {
 	}
 	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/whitelist")
 }
+
+func TestScopedWhitelist(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Whitelist: true,
+			Rules: []PackageRule{
+				{Path: "pkg/internal/helpers"},
+				{Path: "pkg/internal/new"},
+			},
+		},
+		Symbols: Symbols{
+			Whitelist: true,
+			Scoped:    true,
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/new", Name: "Background"},
+			},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/scopedwhitelist")
+}
+
+func TestTrustedPackages(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Whitelist: true,
+			Rules: []PackageRule{
+				{Path: "pkg/internal/new"},
+				{Path: "io/ioutil", Effect: EffectDeny},
+			},
+		},
+		Symbols: Symbols{
+			Whitelist: true,
+		},
+		AllowStd:        true,
+		AllowMainModule: true,
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/trusted")
+}
+
+func TestSelectors(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "@thirdparty", DeniedIn: "pkg/selectors/core/..."},
+				{Path: "@module:pkg/internal/new"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{{Package: "@std", Name: "Exit", DeniedIn: "@main-module"}},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/selectors/core")
+}
+
+func TestEffects(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/...", Effect: EffectDeny},
+				{Path: "pkg/internal/helpers", Effect: EffectAllow},
+				{Path: "pkg/internal/old"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/old", Name: "Background", Effect: EffectAllow},
+				{Package: "pkg/internal/helpers", Name: "Variable"},
+			},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/effects")
+}
+
+func TestSymbolReplacementFixes(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/helpers", Name: "Constant", ReplacementName: "Variable"},
+				{Package: "pkg/internal/old", Name: "Background", ReplacementPackage: "pkg/internal/new"},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/symbolfix")
+}
+
+func TestPackageReplacementFixes(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/legacy", Replacement: "pkg/internal/new"},
+				{Path: "pkg/internal/old", Replacement: "pkg/internal/new"},
+				{Path: "pkg/internal/oldlog", Replacement: "pkg/internal/newlog", Rewrite: "selectors"},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/packagefix")
+}
+
+func TestTemplateFixes(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{
+					Package:            "io/ioutil",
+					Name:               "ReadAll",
+					ReplacementPackage: "io",
+					Call:               "ioutil.ReadAll($r)",
+					Template:           "io.ReadAll($r)",
+				},
+				{
+					Package:            "pkg/internal/errors",
+					Name:               "Wrap",
+					ReplacementPackage: "fmt",
+					Call:               "errors.Wrap($err, $msg)",
+					Template:           `fmt.Errorf("$msg: %w", $err)`,
+				},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/templatefix")
+}
+
+func TestDotImports(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/forbidden"},
+				{Path: "pkg/internal/old", Replacement: "pkg/internal/new"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/helpers", Name: "Constant", ReplacementName: "Variable"},
+				{Package: "pkg/internal/helpers", Name: "Struct"},
+				{Package: "pkg/internal/helpers", Name: "StructFactory"},
+				{Package: "pkg/internal/helpers", Name: "Variable"},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/dotimport")
+}
+
+func TestMethodsAndFields(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/client", Receiver: "*Client", Name: "Do"},
+				{Package: "pkg/internal/client", Receiver: "Client", Name: "Timeout", ReplacementName: "Deadline"},
+				{Package: "pkg/internal/client", Receiver: "Doer", Name: "Do"},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/methods")
+}
+
+func TestIndirectTypes(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/helpers", Name: "StructType"},
+			},
+		},
+		IndirectTypes: true,
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/indirect")
+}
+
+func TestPackagePatterns(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/..."},
+				{Path: "pkg/internal/o*", Replacement: "pkg/internal/new"},
+				{Path: "pkg/internal/oldlog", Replacement: "pkg/internal/newlog"},
+				{Path: "pkg/internal/nested/...", Replacement: "pkg/internal/v2/nested/..."},
+			},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/patterns/packages")
+}
+
+func TestSymbolPatterns(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/...", Name: "Deprecated,Variable"},
+				{Package: "pkg/internal/*", Name: "Deprecated", ReplacementName: "Replacement"},
+				{Package: "pkg/internal/helpers", Name: "Variable", ReplacementName: "Constant"},
+				{Package: "pkg/internal/nested/...", Name: "Variable", ReplacementPackage: "pkg/internal/v2/nested/..."},
+			},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/patterns/symbols")
+}
+
+func TestSymbolNamePatterns(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "pkg/internal/oldlog", Regex: "^Print(f?)$", ReplacementPackage: "pkg/internal/newlog", ReplacementName: "Info$1"},
+				{Package: "pkg/internal/oldlog", Regex: "^Fatal(?P<suffix>f?)$", ReplacementPackage: "pkg/internal/newlog", ReplacementName: "Error${suffix}"},
+				{Package: "pkg/internal/oldlog", Name: "Must*", ReplacementName: "$1"},
+				{Package: "pkg/internal/oldlog", Name: "MustClose"},
+			},
+		},
+	}
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analysis(testConfig), "pkg/symbolnames")
+}
+
+func TestImporterScopes(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "database/sql", AllowedIn: "pkg/scopes/platform/db"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit", AllowedIn: "pkg/scopes/cmd/..."},
+				{Package: "pkg/internal/helpers", Name: "Variable", DeniedIn: "pkg/scopes/service"},
+			},
+		},
+	}
+	analysistest.Run(
+		t,
+		analysistest.TestData(),
+		Analysis(testConfig),
+		"pkg/scopes/cmd/tool",
+		"pkg/scopes/platform/db",
+		"pkg/scopes/service",
+	)
+}
+
+func TestDirectives(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/forbidden"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit"},
+				{Package: "pkg/internal/helpers", Name: "Constant,FuncFactory,Variable"},
+			},
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/directives")
+}
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/forbidden"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit"},
+				{Package: "pkg/internal/helpers", Name: "Variable"},
+			},
+		},
+	}
+	analyzer := Analysis(testConfig)
+	require.NoError(t, analyzer.Flags.Set("baseline", filepath.Join(analysistest.TestData(), "src", "pkg", "baseline", "baseline.yml")))
+
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "pkg/baseline")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+	assert.Equal(t, "pkg/baseline", result.Package)
+	assert.Len(t, result.Findings, 7)
+}
+
+func TestSeverities(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/forbidden", Severity: SeverityWarning},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit", Severity: SeverityInfo},
+				{Package: "pkg/internal/helpers", Name: "Constant", Severity: SeverityWarning},
+				{Package: "pkg/internal/helpers", Name: "Variable"},
+			},
+		},
+	}
+	analyzer := Analysis(testConfig)
+	require.NoError(t, analyzer.Flags.Set("severity", "warning=error,pkg/internal/helpers.Constant=info"))
+
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "pkg/severity")
+	require.Len(t, results, 1)
+	severities := map[string]string{}
+	for _, d := range results[0].Diagnostics {
+		severities[d.Message] = d.Category
+	}
+	assert.Equal(t, map[string]string{
+		"pkg/internal/forbidden should not be used [pkg/internal/forbidden]":               SeverityError,
+		"pkg/internal/helpers.Constant should not be used [pkg/internal/helpers.Constant]": SeverityInfo,
+		"pkg/internal/helpers.Variable should not be used [pkg/internal/helpers.Variable]": SeverityError,
+		"os.Exit should not be used [os.Exit]":                                             SeverityInfo,
+	}, severities)
+}
+
+func TestDeadlines(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "pkg/internal/old", Replacement: "pkg/internal/new", ErrorFrom: "2027-01-01"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit", WarnFrom: "2025-06-01", ErrorFrom: "2026-01-01"},
+				{Package: "pkg/internal/helpers", Name: "Constant", DisabledUntil: "2027-01-01"},
+				{Package: "pkg/internal/helpers", Name: "Variable", WarnFrom: "2026-12-01", ErrorFrom: "2027-01-01"},
+			},
+		},
+		Clock: func() time.Time { return time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	results := analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/deadlines")
+	require.Len(t, results, 1)
+	severities := map[string]string{}
+	for _, d := range results[0].Diagnostics {
+		severities[d.Message] = d.Category
+	}
+	assert.Equal(t, map[string]string{
+		"pkg/internal/old will be forbidden from 2027-01-01; replace with pkg/internal/new [pkg/internal/old]": SeverityWarning,
+		"pkg/internal/helpers.Variable will be forbidden from 2027-01-01 [pkg/internal/helpers.Variable]":      SeverityInfo,
+		"os.Exit should not be used [os.Exit]": SeverityError,
+	}, severities)
+}
+
+func TestRuleMessages(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{
+				Path:    "pkg/internal/forbidden",
+				Message: "$symbol is unmaintained",
+				URL:     "https://example.com/migrations/forbidden",
+			}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "os", Name: "Exit", Message: "$importer should return errors instead of calling $symbol"},
+				{
+					Package:         "pkg/internal/helpers",
+					Name:            "Variable",
+					ReplacementName: "Constant",
+					Message:         "use $replacement instead of $symbol",
+					ErrorFrom:       "2027-01-01",
+				},
+			},
+		},
+		Clock: func() time.Time { return time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	analysistest.Run(t, analysistest.TestData(), Analysis(testConfig), "pkg/messages")
+}
+
+func TestRuleIDs(t *testing.T) {
+	t.Parallel()
+
+	testConfig := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{ID: "no-forbidden", Path: "pkg/internal/forbidden"}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{ID: "no-exit", Package: "os", Name: "Exit"},
+				{ID: "legacy-constant", Package: "pkg/internal/helpers", Name: "Constant"},
+				{ID: "migrate-helpers", Package: "pkg/internal/helpers", Name: "Variable", ReplacementName: "Constant"},
+			},
+		},
+	}
+	analyzer := Analysis(testConfig)
+	require.NoError(t, analyzer.Flags.Set("enable", "no-*,migrate-helpers"))
+	require.NoError(t, analyzer.Flags.Set("disable", "no-exit"))
+	analysistest.Run(t, analysistest.TestData(), analyzer, "pkg/ruleids")
+}
+
+func TestConfigurationDiscovery(t *testing.T) {
+	t.Parallel()
+
+	analysistest.Run(t, analysistest.TestData(), Analysis(nil), "pkg/hierarchy", "pkg/hierarchy/team")
+}
@@ -0,0 +1,182 @@
+package anathema
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A baseline records the findings that existed when a rule was introduced so that only new findings
+// are reported. Findings are identified by a fingerprint rather than by their position so that the
+// baseline survives unrelated changes to the code around them. As several findings may share the
+// same fingerprint the baseline records how many of them are tolerated.
+
+// Fingerprint identifies a finding independently of its exact position.
+type Fingerprint struct {
+	Rule     string `yaml:"rule,omitempty"`
+	Package  string `yaml:"package"`
+	Function string `yaml:"function,omitempty"` // Empty for findings outside of functions.
+	Symbol   string `yaml:"symbol"`
+}
+
+func (f Fingerprint) String() string {
+	location := f.Package
+	if f.Function != "" {
+		location += "." + f.Function
+	}
+	if f.Rule == "" {
+		return fmt.Sprintf("%s in %s", f.Symbol, location)
+	}
+	return fmt.Sprintf("%s in %s (rule %s)", f.Symbol, location, f.Rule)
+}
+
+type BaselineEntry struct {
+	Fingerprint `yaml:",inline"`
+	Count       int `yaml:"count"`
+}
+
+type Baseline struct {
+	Findings []BaselineEntry `yaml:"findings"`
+}
+
+// Finding describes a finding in the Result of the analyzer.
+type Finding struct {
+	Fingerprint
+	Pos token.Pos
+}
+
+// Result is the result of the analyzer for a single package. It lists all the findings in the package
+// that were not suppressed by a directive, including those that are tolerated by the baseline.
+type Result struct {
+	Package  string // Path of the package as it appears in fingerprints.
+	Findings []Finding
+}
+
+// NewBaseline creates a baseline that tolerates the given number of findings for each fingerprint.
+func NewBaseline(counts map[Fingerprint]int) *Baseline {
+	b := &Baseline{}
+	for fp, count := range counts {
+		b.Findings = append(b.Findings, BaselineEntry{Fingerprint: fp, Count: count})
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		x, y := b.Findings[i], b.Findings[j]
+		switch {
+		case x.Package != y.Package:
+			return x.Package < y.Package
+		case x.Function != y.Function:
+			return x.Function < y.Function
+		case x.Symbol != y.Symbol:
+			return x.Symbol < y.Symbol
+		default:
+			return x.Rule < y.Rule
+		}
+	})
+	return b
+}
+
+// LoadBaseline reads and parses the baseline file at the given path.
+func LoadBaseline(path string) (*Baseline, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the specified baseline at %q: %v", path, err)
+	}
+
+	b := &Baseline{}
+	if err = yaml.Unmarshal(raw, b); err != nil {
+		return nil, fmt.Errorf("the baseline in %q could not be parsed: %v", path, err)
+	}
+	return b, nil
+}
+
+// Save writes the baseline to the given path.
+func (b *Baseline) Save(path string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Stale returns the entries of the baseline for the given packages that tolerate more findings than
+// there currently are, with the number of findings that have since been fixed as their count.
+func (b *Baseline) Stale(counts map[Fingerprint]int, packages map[string]bool) []BaselineEntry {
+	var stale []BaselineEntry
+	for _, entry := range b.Findings {
+		if !packages[entry.Package] {
+			continue
+		}
+		if fixed := entry.Count - counts[entry.Fingerprint]; fixed > 0 {
+			stale = append(stale, BaselineEntry{Fingerprint: entry.Fingerprint, Count: fixed})
+		}
+	}
+	return stale
+}
+
+// allowance keeps track of the findings that the baseline still tolerates over an entire run. As a
+// file may be analysed as part of several variants of its package, e.g with and without the package's
+// tests, findings are identified by their position as well so that each only uses up the allowance
+// once.
+type allowance struct {
+	mu        sync.Mutex
+	remaining map[Fingerprint]int
+	tolerated map[string]bool // Whether the finding at a position is tolerated by the baseline.
+}
+
+func newAllowance(b *Baseline) *allowance {
+	a := &allowance{remaining: map[Fingerprint]int{}, tolerated: map[string]bool{}}
+	if b != nil {
+		for _, entry := range b.Findings {
+			a.remaining[entry.Fingerprint] += entry.Count
+		}
+	}
+	return a
+}
+
+// tolerates reports whether the baseline tolerates the finding with the given fingerprint at the
+// given position.
+func (a *allowance) tolerates(fp Fingerprint, pos token.Position) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := fmt.Sprintf("%s:%d: %s", pos.Filename, pos.Offset, fp)
+	if tolerated, ok := a.tolerated[key]; ok {
+		return tolerated
+	}
+	tolerated := a.remaining[fp] > 0
+	if tolerated {
+		a.remaining[fp]--
+	}
+	a.tolerated[key] = tolerated
+	return tolerated
+}
+
+// enclosingFunction returns the name of the function declaration that contains the given position,
+// qualified with its receiver type for methods, e.g 'Client.Do'.
+func enclosingFunction(info *types.Info, file *ast.File, pos token.Pos) string {
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || pos < fd.Pos() || pos >= fd.End() {
+			continue
+		}
+		fn, ok := info.Defs[fd.Name].(*types.Func)
+		if !ok {
+			return fd.Name.Name
+		}
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			if named := namedType(recv.Type()); named != nil {
+				return named.Obj().Name() + "." + fd.Name.Name
+			}
+		}
+		return fd.Name.Name
+	}
+	return ""
+}
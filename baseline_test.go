@@ -0,0 +1,62 @@
+package anathema
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselineRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	exit := Fingerprint{Rule: "os.Exit", Package: "pkg", Function: "main", Symbol: "os.Exit"}
+	sql := Fingerprint{Rule: "database/sql", Package: "pkg", Symbol: "database/sql"}
+	baseline := NewBaseline(map[Fingerprint]int{exit: 2, sql: 1})
+	assert.Equal(t, &Baseline{Findings: []BaselineEntry{
+		{Fingerprint: sql, Count: 1},
+		{Fingerprint: exit, Count: 2},
+	}}, baseline)
+
+	dir, err := ioutil.TempDir("", "anathema")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "baseline.yml")
+	require.NoError(t, baseline.Save(path))
+	loaded, err := LoadBaseline(path)
+	require.NoError(t, err)
+	assert.Equal(t, baseline, loaded)
+}
+
+func TestBaselineStale(t *testing.T) {
+	t.Parallel()
+
+	exit := Fingerprint{Rule: "os.Exit", Package: "pkg", Function: "main", Symbol: "os.Exit"}
+	sql := Fingerprint{Rule: "database/sql", Package: "pkg", Symbol: "database/sql"}
+	other := Fingerprint{Rule: "os.Exit", Package: "other", Function: "main", Symbol: "os.Exit"}
+	baseline := NewBaseline(map[Fingerprint]int{exit: 3, sql: 1, other: 1})
+
+	stale := baseline.Stale(map[Fingerprint]int{exit: 1, sql: 2}, map[string]bool{"pkg": true})
+	assert.Equal(t, []BaselineEntry{{Fingerprint: exit, Count: 2}}, stale)
+}
+
+func TestBaselineAllowance(t *testing.T) {
+	t.Parallel()
+
+	exit := Fingerprint{Rule: "os.Exit", Package: "pkg", Function: "main", Symbol: "os.Exit"}
+	allowance := newAllowance(NewBaseline(map[Fingerprint]int{exit: 1}))
+	first := token.Position{Filename: "main.go", Offset: 42, Line: 4, Column: 2}
+	second := token.Position{Filename: "main.go", Offset: 64, Line: 5, Column: 2}
+
+	// A finding that is reported for each variant of its package only uses up the allowance once.
+	assert.True(t, allowance.tolerates(exit, first))
+	assert.True(t, allowance.tolerates(exit, first))
+	assert.False(t, allowance.tolerates(exit, second))
+	assert.False(t, allowance.tolerates(exit, second))
+	assert.False(t, allowance.tolerates(Fingerprint{Package: "pkg", Symbol: "os.Exit"}, first))
+}
@@ -0,0 +1,165 @@
+package anathema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The paths, names and importers of rules are comma-separated lists of elements that are brace
+// expanded, e.g 'go/{ast,parser}' lists 'go/ast' and 'go/parser'. Groups may be nested, e.g
+// 'google.golang.org/api/{compute/{v1,beta},storage/v1}', and successive groups are combined with the
+// leftmost group varying the slowest, e.g 'k8s.io/api/{apps,batch}/{v1,v1beta1}' lists
+// 'k8s.io/api/apps/v1' and 'k8s.io/api/apps/v1beta1' before the batch packages. A group may also be a
+// range of integers with an optional step, e.g 'v{1..3}' or '{10..0..5}', which are zero-padded to
+// the same width when either bound is. A backslash escapes the character that follows it while the
+// '${name}' references of replacement names are kept as is. Replacements are expanded in the same
+// order as what they replace so that the two can be paired.
+
+// maxRangeLength bounds the number of elements of a range, which are otherwise easily mistyped.
+const maxRangeLength = 1000
+
+var rangeRE = regexp.MustCompile(`^(-?\d+)\.\.(-?\d+)(?:\.\.(\d+))?}`)
+
+func expandLine(line string) ([]string, error) {
+	p := &braceParser{spec: line}
+	specs, err := p.alternatives()
+	if err != nil {
+		return nil, fmt.Errorf("target specification %q contained an error: %s", line, err)
+	}
+	for _, spec := range specs {
+		if spec == "" {
+			return nil, fmt.Errorf("target specification %q contained an empty element", line)
+		}
+	}
+	return specs, nil
+}
+
+// braceParser expands a specification with a recursive descent over its groups.
+type braceParser struct {
+	spec  string
+	pos   int
+	depth int // Number of groups that are currently open.
+}
+
+// alternatives expands the comma-separated elements up to the end of the specification or, within a
+// group, up to its closing brace.
+func (p *braceParser) alternatives() ([]string, error) {
+	var expanded []string
+	for {
+		element, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, element...)
+		if p.pos == len(p.spec) || p.spec[p.pos] != ',' {
+			return expanded, nil
+		}
+		p.pos++
+	}
+}
+
+// sequence expands the literals and groups up to the next comma or closing brace into their cartesian
+// product.
+func (p *braceParser) sequence() ([]string, error) {
+	expanded := []string{""}
+	combine := func(suffixes ...string) {
+		product := make([]string, 0, len(expanded)*len(suffixes))
+		for _, prefix := range expanded {
+			for _, suffix := range suffixes {
+				product = append(product, prefix+suffix)
+			}
+		}
+		expanded = product
+	}
+
+	for p.pos < len(p.spec) {
+		switch c := p.spec[p.pos]; {
+		case c == ',' || (c == '}' && p.depth > 0):
+			return expanded, nil
+		case c == '}':
+			return nil, fmt.Errorf("unmatched closing brace at offset %d", p.pos)
+		case c == '\\':
+			if p.pos+1 == len(p.spec) {
+				return nil, errors.New("trailing backslash")
+			}
+			combine(p.spec[p.pos+1 : p.pos+2])
+			p.pos += 2
+		case strings.HasPrefix(p.spec[p.pos:], "${"):
+			end := strings.IndexByte(p.spec[p.pos:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed reference at offset %d", p.pos)
+			}
+			combine(p.spec[p.pos : p.pos+end+1])
+			p.pos += end + 1
+		case c == '{':
+			group, err := p.group()
+			if err != nil {
+				return nil, err
+			}
+			combine(group...)
+		default:
+			combine(string(c))
+			p.pos++
+		}
+	}
+	return expanded, nil
+}
+
+func (p *braceParser) group() ([]string, error) {
+	start := p.pos
+	p.pos++
+	if m := rangeRE.FindStringSubmatch(p.spec[p.pos:]); m != nil {
+		p.pos += len(m[0])
+		return expandRange(m[1], m[2], m[3])
+	}
+
+	p.depth++
+	expanded, err := p.alternatives()
+	if err != nil {
+		return nil, err
+	} else if p.pos == len(p.spec) {
+		return nil, fmt.Errorf("unclosed brace at offset %d", start)
+	}
+	p.depth--
+	p.pos++
+	return expanded, nil
+}
+
+func expandRange(from string, to string, step string) ([]string, error) {
+	first, err := strconv.Atoi(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range bound %q: %s", from, err)
+	}
+	last, err := strconv.Atoi(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range bound %q: %s", to, err)
+	}
+	increment := 1
+	if step != "" {
+		if increment, err = strconv.Atoi(step); err != nil || increment == 0 {
+			return nil, fmt.Errorf("invalid range step %q", step)
+		}
+	}
+	if last < first {
+		increment = -increment
+	}
+	if (last-first)/increment >= maxRangeLength {
+		return nil, fmt.Errorf("range {%s..%s} has more than %d elements", from, to, maxRangeLength)
+	}
+
+	var width int
+	for _, bound := range []string{from, to} {
+		if digits := strings.TrimPrefix(bound, "-"); len(digits) > 1 && digits[0] == '0' && len(bound) > width {
+			width = len(bound)
+		}
+	}
+
+	var expanded []string
+	for value := first; (increment > 0 && value <= last) || (increment < 0 && value >= last); value += increment {
+		expanded = append(expanded, fmt.Sprintf("%0*d", width, value))
+	}
+	return expanded, nil
+}
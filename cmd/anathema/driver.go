@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/Helcaraxan/anathema"
+)
+
+// outcome holds the outcome of running the analyzer over a set of packages.
+type outcome struct {
+	fset        *token.FileSet
+	diagnostics []analysis.Diagnostic
+	results     []*anathema.Result
+}
+
+// analyse loads the packages that match the given patterns and runs the analyzer on each of them.
+// Diagnostics that are reported for several variants of the same package are only kept once.
+func analyse(a *analysis.Analyzer, patterns []string, tests bool) (*outcome, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedTypesSizes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Tests: tests,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	} else if packages.PrintErrors(pkgs) > 0 {
+		return nil, errors.New("the packages could not be loaded")
+	}
+
+	o := &outcome{fset: token.NewFileSet()}
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue // Test executables only contain generated code.
+		}
+		o.fset = pkg.Fset
+
+		pass := &analysis.Pass{
+			Analyzer:   a,
+			Fset:       pkg.Fset,
+			Files:      pkg.Syntax,
+			OtherFiles: pkg.OtherFiles,
+			Pkg:        pkg.Types,
+			TypesInfo:  pkg.TypesInfo,
+			TypesSizes: pkg.TypesSizes,
+			ResultOf:   map[*analysis.Analyzer]interface{}{},
+			Report: func(d analysis.Diagnostic) {
+				key := fmt.Sprintf("%s: %s", pkg.Fset.Position(d.Pos), d.Message)
+				if !seen[key] {
+					seen[key] = true
+					o.diagnostics = append(o.diagnostics, d)
+				}
+			},
+		}
+		result, err := a.Run(pass)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pkg.ID, err)
+		}
+		o.results = append(o.results, result.(*anathema.Result))
+	}
+
+	sort.SliceStable(o.diagnostics, func(i, j int) bool {
+		x, y := o.fset.Position(o.diagnostics[i].Pos), o.fset.Position(o.diagnostics[j].Pos)
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return x.Offset < y.Offset
+	})
+	return o, nil
+}
+
+// counts returns the number of distinct findings for each fingerprint. Findings in files that are part
+// of several variants of a package are only counted once.
+func (o *outcome) counts() map[anathema.Fingerprint]int {
+	counts := map[anathema.Fingerprint]int{}
+	seen := map[string]bool{}
+	for _, result := range o.results {
+		for _, f := range result.Findings {
+			key := fmt.Sprintf("%s: %s", o.fset.Position(f.Pos), f.Fingerprint)
+			if !seen[key] {
+				seen[key] = true
+				counts[f.Fingerprint]++
+			}
+		}
+	}
+	return counts
+}
+
+// packages returns the paths of the analysed packages as they appear in fingerprints.
+func (o *outcome) packages() map[string]bool {
+	paths := map[string]bool{}
+	for _, result := range o.results {
+		paths[result.Package] = true
+	}
+	return paths
+}
+
+// fileEdit is an edit of a suggested fix in terms of the offsets within its file, which unlike positions
+// do not depend on the variant of the package that reported the fix.
+type fileEdit struct {
+	start   int
+	end     int
+	newText string
+}
+
+// overlaps reports whether the edits can not both be applied. Identical edits are applied once while
+// insertions at the same offset are applied in order.
+func (e fileEdit) overlaps(other fileEdit) bool {
+	switch {
+	case e == other:
+		return false
+	case e.start == e.end:
+		return other.start < e.start && e.start < other.end
+	case other.start == other.end:
+		return e.start < other.start && other.start < e.end
+	default:
+		return e.start < other.end && other.start < e.end
+	}
+}
+
+// applyFixes applies the suggested fixes of the diagnostics to the files that they concern. The edits
+// that several fixes have in common, such as those that add the same import, are only applied once. A
+// fix that conflicts with the fixes before it is skipped as a whole and its diagnostic returned. Files
+// are only written once all edits are known, each by replacing it with a complete new version.
+func (o *outcome) applyFixes() ([]analysis.Diagnostic, error) {
+	edits := map[string][]fileEdit{}
+	var skipped []analysis.Diagnostic
+	for _, d := range o.diagnostics {
+		for _, fix := range d.SuggestedFixes {
+			accepted := map[string][]fileEdit{}
+			conflict := false
+			for _, edit := range fix.TextEdits {
+				start, end := o.fset.Position(edit.Pos), o.fset.Position(edit.End)
+				e := fileEdit{start: start.Offset, end: end.Offset, newText: string(edit.NewText)}
+				for _, prev := range edits[start.Filename] {
+					conflict = conflict || e.overlaps(prev)
+				}
+				accepted[start.Filename] = append(accepted[start.Filename], e)
+			}
+			if conflict {
+				skipped = append(skipped, d)
+				continue
+			}
+			for file, fileEdits := range accepted {
+				for _, e := range fileEdits {
+					if !containsEdit(edits[file], e) {
+						edits[file] = append(edits[file], e)
+					}
+				}
+			}
+		}
+	}
+
+	contents := map[string][]byte{}
+	for file, fileEdits := range edits {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if contents[file], err = applyEdits(content, fileEdits); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	for file, content := range contents {
+		if err := writeFile(file, content); err != nil {
+			return nil, err
+		}
+	}
+	return skipped, nil
+}
+
+func containsEdit(edits []fileEdit, edit fileEdit) bool {
+	for _, e := range edits {
+		if e == edit {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEdits returns the content with the given edits applied and formatted if it is valid Go code.
+// Insertions at the same offset keep their order and precede any other edit at that offset.
+func applyEdits(content []byte, edits []fileEdit) ([]byte, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end-edits[i].start < edits[j].end-edits[j].start
+	})
+
+	var out bytes.Buffer
+	var cur int
+	for _, e := range edits {
+		if e.start < cur || e.end > len(content) {
+			return nil, errors.New("the suggested fixes contain invalid edits")
+		}
+		out.Write(content[cur:e.start])
+		out.WriteString(e.newText)
+		cur = e.end
+	}
+	out.Write(content[cur:])
+
+	if formatted, err := format.Source(out.Bytes()); err == nil {
+		return formatted, nil
+	}
+	return out.Bytes(), nil
+}
+
+// writeFile replaces the file with the given content by renaming a temporary file over it, so that the
+// file is either left untouched or fully written.
+func writeFile(path string, content []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	} else if err = tmp.Close(); err != nil {
+		return err
+	} else if err = os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
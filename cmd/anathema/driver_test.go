@@ -0,0 +1,50 @@
+package main
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestApplyFixes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "anathema")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	const original = "package main\n\nimport \"old\"\n\nvar (\n\t_ = old.A\n\t_ = old.B\n)\n"
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, ioutil.WriteFile(path, []byte(original), 0644))
+
+	fset := token.NewFileSet()
+	tf := fset.AddFile(path, -1, len(original))
+	tf.SetLinesForContent([]byte(original))
+	edit := func(old string, newText string) analysis.TextEdit {
+		offset := strings.Index(original, old)
+		return analysis.TextEdit{Pos: tf.Pos(offset), End: tf.Pos(offset + len(old)), NewText: []byte(newText)}
+	}
+	diagnostic := func(edits ...analysis.TextEdit) analysis.Diagnostic {
+		return analysis.Diagnostic{Pos: edits[0].Pos, SuggestedFixes: []analysis.SuggestedFix{{TextEdits: edits}}}
+	}
+
+	// Both fixes rewrite both uses and replace the import, which is only done once.
+	rewrites := []analysis.TextEdit{edit(`"old"`, `"new"`), edit("old.A", "new.A"), edit("old.B", "new.B")}
+	conflicting := diagnostic(edit("old.A", "other.A"))
+	o := &outcome{fset: fset, diagnostics: []analysis.Diagnostic{diagnostic(rewrites...), diagnostic(rewrites...), conflicting}}
+
+	skipped, err := o.applyFixes()
+	require.NoError(t, err)
+	assert.Equal(t, []analysis.Diagnostic{conflicting}, skipped)
+
+	fixed, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nimport \"new\"\n\nvar (\n\t_ = new.A\n\t_ = new.B\n)\n", string(fixed))
+}
@@ -1,11 +1,206 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/singlechecker"
 
 	"github.com/Helcaraxan/anathema"
 )
 
+const usage = `Usage:
+  anathema [-config file] [-baseline file] [-severity overrides] [-enable ids] [-disable ids] [-fix] [-test=false] packages...
+  anathema [-json | -c n] [flags] packages...
+  anathema baseline [-config file] -baseline file [-check] [-test=false] packages...
+  anathema config validate -config file
+`
+
 func main() {
-	singlechecker.Main(anathema.Analysis(nil))
+	a := anathema.Analysis(nil)
+
+	args := os.Args[1:]
+	switch {
+	case len(args) > 1 && args[0] == "config" && args[1] == "validate":
+		os.Exit(validateConfig(args[2:]))
+	case len(args) > 0 && args[0] == "baseline":
+		os.Exit(baseline(a, args[1:]))
+	case usesStandardDriver(a, args):
+		singlechecker.Main(a)
+	default:
+		os.Exit(check(a, args))
+	}
+}
+
+// standardFlags are the flags that only the standard analysis driver supports.
+var standardFlags = map[string]bool{
+	"V":          true,
+	"flags":      true,
+	"json":       true,
+	"c":          true,
+	"debug":      true,
+	"cpuprofile": true,
+	"memprofile": true,
+	"trace":      true,
+}
+
+// usesStandardDriver reports whether the arguments ask for the standard analysis driver, either through
+// one of its own flags or by passing the file that describes the unit to analyse for 'go vet -vettool'.
+func usesStandardDriver(a *analysis.Analyzer, args []string) bool {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return idx == len(args)-1 && strings.HasSuffix(arg, ".cfg")
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		} else if f := a.Flags.Lookup(name); f != nil && !isBoolFlag(f) {
+			idx++ // The value of the flag is the next argument.
+		}
+		if standardFlags[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// check reports the findings in the given packages together with their severity and applies their
+// suggested fixes if requested. Only findings with an error severity result in a failure, unlike with
+// the standard driver which fails for any finding.
+func check(a *analysis.Analyzer, args []string) int {
+	flags := flag.NewFlagSet("anathema", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	a.Flags.VisitAll(func(f *flag.Flag) {
+		flags.Var(f.Value, f.Name, f.Usage)
+	})
+	fix := flags.Bool("fix", false, "apply all suggested fixes")
+	tests := flags.Bool("test", true, "also analyse test files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	o, err := analyse(a, flags.Args(), *tests)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var errors int
+	for _, d := range o.diagnostics {
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", o.fset.Position(d.Pos), d.Category, d.Message)
+		if d.Category == anathema.SeverityError {
+			errors++
+		}
+	}
+
+	if *fix {
+		skipped, err := o.applyFixes()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, d := range skipped {
+			fmt.Fprintf(os.Stderr, "%s: the fix conflicts with another one and was not applied\n", o.fset.Position(d.Pos))
+		}
+	}
+	if errors > 0 {
+		return 3
+	}
+	return 0
+}
+
+// baseline records all current findings in the given packages to the baseline file or, when checking
+// it instead, reports the entries of the baseline that have since been fixed.
+func baseline(a *analysis.Analyzer, args []string) int {
+	flags := flag.NewFlagSet("baseline", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	a.Flags.VisitAll(func(f *flag.Flag) {
+		flags.Var(f.Value, f.Name, f.Usage)
+	})
+	check := flags.Bool("check", false, "report the entries of the baseline that have been fixed instead of recording it")
+	tests := flags.Bool("test", true, "also analyse test files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// The findings are collected from scratch rather than tolerating those of the baseline.
+	baselinePath := a.Flags.Lookup("baseline").Value.String()
+	if baselinePath == "" {
+		fmt.Fprintln(os.Stderr, "No baseline file was specified.")
+		return 2
+	} else if err := a.Flags.Set("baseline", ""); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	o, err := analyse(a, flags.Args(), *tests)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	counts := o.counts()
+
+	if *check {
+		b, err := anathema.LoadBaseline(baselinePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		stale := b.Stale(counts, o.packages())
+		for _, entry := range stale {
+			fmt.Fprintf(os.Stderr, "%s: %d finding(s) of %s have been fixed and can be removed\n", baselinePath, entry.Count, entry.Fingerprint)
+		}
+		if len(stale) > 0 {
+			return 3
+		}
+		return 0
+	}
+
+	if err = anathema.NewBaseline(counts).Save(baselinePath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	fmt.Printf("Recorded %d finding(s) in %s.\n", total, baselinePath)
+	return 0
+}
+
+// validateConfig checks the configuration and reports the order in which overlapping rules are applied.
+func validateConfig(args []string) int {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to the configuration file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	} else if *configPath == "" {
+		fmt.Fprintln(os.Stderr, "No configuration file was specified.")
+		return 2
+	}
+
+	c, err := anathema.LoadConfiguration(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	precedence, err := c.Precedence()
+	if err != nil {
+		// Each error of the configuration is printed on a line of its own, prefixed by its location.
+		fmt.Fprintf(os.Stderr, "The configuration in %q is invalid:\n%v\n", *configPath, err)
+		return 1
+	}
+	for _, line := range precedence {
+		fmt.Println(line)
+	}
+	return 0
 }
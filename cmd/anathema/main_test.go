@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Helcaraxan/anathema"
+)
+
+func TestCheckSeverities(t *testing.T) {
+	t.Parallel()
+
+	for config, expected := range map[string]int{
+		"testdata/severity/warning.yml": 0,
+		"testdata/severity/error.yml":   3,
+	} {
+		a := anathema.Analysis(nil)
+		assert.Equal(t, expected, check(a, []string{"-config", config, "./testdata/severity"}), config)
+	}
+}
+
+func TestUsesStandardDriver(t *testing.T) {
+	t.Parallel()
+
+	a := anathema.Analysis(nil)
+	testcases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"./..."}, false},
+		{[]string{"-config", "anathema.cfg", "./..."}, false},
+		{[]string{"-severity=os.Exit=warning", "-fix", "."}, false},
+		{[]string{"-fix", "-c=2", "."}, true},
+		{[]string{"--json", "./..."}, true},
+		{[]string{"-V=full"}, true},
+		{[]string{"-unsafeptr=false", "unit.cfg"}, true},
+	}
+	for _, testcase := range testcases {
+		assert.Equal(t, testcase.expected, usesStandardDriver(a, testcase.args), testcase.args)
+	}
+}
@@ -0,0 +1,293 @@
+package anathema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A configuration file may be composed of other local files, the paths of which are relative to the
+// file that references them:
+//
+//   - The files that it extends are merged in order as if each was nested below the previous one,
+//     after which the file itself refines the result. Rules take precedence over, and replace, the
+//     rules of the files that they extend that have the same ID.
+//   - The files that it includes contribute their rules as if they were part of the file itself. Rules
+//     with the same ID in an included file and in the file itself are an error.
+//
+// In both cases packages and symbols are whitelisted, symbol allow-lists scoped, indirect types
+// detected, and the standard library or main module allowed, if any of the files says so. When the
+// rules of a file and of a file that it extends list the same package or symbol, the former takes
+// precedence. Two rules of included files that list the same package or symbol are an error, as are
+// rules that replace a package or symbol with one that is denied by another file. Errors about rules
+// point at the line and column at which they are defined.
+
+// loader loads configuration files together with the files that they extend or include.
+type loader struct {
+	loading map[string]bool // Files that are being loaded, to detect cycles.
+}
+
+func (l *loader) load(path string) (*Configuration, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &ConfigError{File: path, Err: err}
+	} else if l.loading[abs] {
+		return nil, &ConfigError{File: path, Err: errors.New("the configuration extends or includes itself")}
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{File: path, Err: err}
+	}
+
+	// Unknown keys are rejected as they would otherwise silently result in rules that match nothing.
+	c := &Configuration{}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err = dec.Decode(c); err != nil && err != io.EOF {
+		return nil, yamlError(path, err)
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(raw, &root); err == nil {
+		locateRules(path, &root, c)
+	}
+
+	relative := func(ref string) string {
+		if filepath.IsAbs(ref) {
+			return ref
+		}
+		return filepath.Join(filepath.Dir(path), ref)
+	}
+
+	for _, ref := range c.Include {
+		included, err := l.load(relative(ref))
+		if err != nil {
+			return nil, err
+		}
+		c.Packages.Whitelist = c.Packages.Whitelist || included.Packages.Whitelist
+		c.Packages.Rules = append(c.Packages.Rules, included.Packages.Rules...)
+		c.Symbols.Whitelist = c.Symbols.Whitelist || included.Symbols.Whitelist
+		c.Symbols.Scoped = c.Symbols.Scoped || included.Symbols.Scoped
+		c.Symbols.Rules = append(c.Symbols.Rules, included.Symbols.Rules...)
+		c.IndirectTypes = c.IndirectTypes || included.IndirectTypes
+		c.AllowStd = c.AllowStd || included.AllowStd
+		c.AllowMainModule = c.AllowMainModule || included.AllowMainModule
+	}
+
+	var base *Configuration
+	for _, ref := range c.Extends {
+		extended, err := l.load(relative(ref))
+		if err != nil {
+			return nil, err
+		}
+		base = base.merge(extended)
+	}
+	c.Extends, c.Include = nil, nil
+	return base.merge(c), nil
+}
+
+// locateRules records the location of each rule of the configuration, and of the values of their keys,
+// within the YAML document that it was decoded from.
+func locateRules(path string, root *yaml.Node, c *Configuration) {
+	rules := func(section string) []*yaml.Node {
+		n := root
+		if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+		n = mappingValue(mappingValue(n, section), "rules")
+		if n == nil || n.Kind != yaml.SequenceNode {
+			return nil
+		}
+		return n.Content
+	}
+	for idx, n := range rules("packages") {
+		if idx < len(c.Packages.Rules) {
+			c.Packages.Rules[idx].loc, c.Packages.Rules[idx].keys = locate(path, n)
+		}
+	}
+	for idx, n := range rules("symbols") {
+		if idx < len(c.Symbols.Rules) {
+			c.Symbols.Rules[idx].loc, c.Symbols.Rules[idx].keys = locate(path, n)
+		}
+	}
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(n.Content); idx += 2 {
+		if n.Content[idx].Value == key {
+			return n.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+func locate(path string, n *yaml.Node) (location, map[string]location) {
+	keys := map[string]location{}
+	if n.Kind == yaml.MappingNode {
+		for idx := 0; idx+1 < len(n.Content); idx += 2 {
+			value := n.Content[idx+1]
+			keys[n.Content[idx].Value] = location{file: path, line: value.Line, column: value.Column}
+		}
+	}
+	return location{file: path, line: n.Line, column: n.Column}, keys
+}
+
+var (
+	yamlLineRE     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownFieldRE = regexp.MustCompile(`field (\S+) not found in type \S+\.(\w+)`)
+)
+
+// configTypes are the types of the configuration file that may contain unknown keys.
+var configTypes = map[string]reflect.Type{
+	"Configuration": reflect.TypeOf(Configuration{}),
+	"Packages":      reflect.TypeOf(Packages{}),
+	"PackageRule":   reflect.TypeOf(PackageRule{}),
+	"Symbols":       reflect.TypeOf(Symbols{}),
+	"SymbolRule":    reflect.TypeOf(SymbolRule{}),
+}
+
+// yamlError converts an error of the YAML decoder into ConfigErrors that locate each of its problems
+// in the file.
+func yamlError(path string, err error) error {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+
+	var errs ConfigErrors
+	for _, msg := range messages {
+		if m := unknownFieldRE.FindStringSubmatch(msg); m != nil {
+			msg = strings.Replace(msg, m[0], unknownKey(m[2], m[1]), 1)
+		}
+
+		e := &ConfigError{File: path}
+		if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			msg = m[2]
+		}
+		e.Err = errors.New(msg)
+		errs.add(e)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs.err()
+}
+
+// unknownKey describes a key that is unknown to the given type of the configuration, together with the
+// closest known key if there is one that is likely to be a misspelling of it.
+func unknownKey(typeName string, key string) string {
+	msg := fmt.Sprintf("unknown key %q in %s", key, typeName)
+	t, ok := configTypes[typeName]
+	if !ok {
+		return msg
+	}
+
+	var closest string
+	distance := -1
+	for idx := 0; idx < t.NumField(); idx++ {
+		known := strings.Split(t.Field(idx).Tag.Get("yaml"), ",")[0]
+		if known == "" || known == "-" {
+			continue
+		}
+		if d := editDistance(key, known); distance < 0 || d < distance {
+			closest, distance = known, d
+		}
+	}
+	if distance < 0 || distance > len(closest)/2 {
+		return msg
+	}
+	return fmt.Sprintf("%s, did you mean %q?", msg, closest)
+}
+
+// editDistance returns the Levenshtein distance between the two strings.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// merge returns the configuration that results from refining the configuration with a nested one.
+func (c *Configuration) merge(nested *Configuration) *Configuration {
+	if c == nil {
+		return nested
+	}
+
+	merged := &Configuration{
+		Packages: Packages{
+			Whitelist: c.Packages.Whitelist || nested.Packages.Whitelist,
+			Rules:     append([]PackageRule{}, nested.Packages.Rules...),
+		},
+		Symbols: Symbols{
+			Whitelist: c.Symbols.Whitelist || nested.Symbols.Whitelist,
+			Scoped:    c.Symbols.Scoped || nested.Symbols.Scoped,
+			Rules:     append([]SymbolRule{}, nested.Symbols.Rules...),
+		},
+		IndirectTypes:   c.IndirectTypes || nested.IndirectTypes,
+		AllowStd:        c.AllowStd || nested.AllowStd,
+		AllowMainModule: c.AllowMainModule || nested.AllowMainModule,
+		Clock:           c.Clock,
+	}
+	if nested.Clock != nil {
+		merged.Clock = nested.Clock
+	}
+
+	overridden := map[string]bool{}
+	for _, r := range nested.Packages.Rules {
+		overridden[r.id()] = true
+	}
+	for _, r := range nested.Symbols.Rules {
+		overridden[r.id()] = true
+	}
+	for _, r := range c.Packages.Rules {
+		if !overridden[r.id()] {
+			r.inherited++
+			merged.Packages.Rules = append(merged.Packages.Rules, r)
+		}
+	}
+	for _, r := range c.Symbols.Rules {
+		if !overridden[r.id()] {
+			r.inherited++
+			merged.Symbols.Rules = append(merged.Symbols.Rules, r)
+		}
+	}
+	return merged
+}
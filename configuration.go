@@ -2,260 +2,1204 @@ package anathema
 
 import (
 	"fmt"
-	"io/ioutil"
-	"log"
+	"go/token"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
 type Configuration struct {
+	// Extends and Include list other configuration files that are merged with this one.
+	Extends []string `yaml:"extends"`
+	Include []string `yaml:"include"`
+
 	Packages Packages `yaml:"packages"`
 	Symbols  Symbols  `yaml:"symbols"`
+
+	// IndirectTypes enables the detection of expressions and variables whose type is, or contains, a
+	// type that is forbidden by the package or symbol rules even if that type is never named.
+	IndirectTypes bool `yaml:"indirect_types"`
+
+	// AllowStd and AllowMainModule allow the packages of the standard library and of the module of the
+	// analysed package respectively, which lets allow-lists focus on third-party dependencies. They stand
+	// for the '{path: "@std", effect: allow}' and '{path: "@main-module", effect: allow}' rules, together
+	// with the equivalent symbol rules when symbols are whitelisted, unless rules list these already.
+	AllowStd        bool `yaml:"allow_std"`
+	AllowMainModule bool `yaml:"allow_main_module"`
+
+	// Clock returns the time at which the deadlines of rules are evaluated. It defaults to time.Now.
+	Clock func() time.Time `yaml:"-"`
 }
 
 type Packages struct {
+	// Whitelist gives the rules EffectAllow by default and denies all the packages that no rule matches,
+	// as a '{id: packages-whitelist, path: "...", effect: deny}' rule would.
 	Whitelist bool          `yaml:"whitelist"`
 	Rules     []PackageRule `yaml:"rules"`
 }
 
 type PackageRule struct {
-	Path        string `yaml:"path"`
+	// ID identifies the rule in diagnostics, directives, baselines and flags. It defaults to each of the
+	// paths that the Path expands to.
+	ID   string `yaml:"id"`
+	Path string `yaml:"path"`
+	// Effect is either EffectDeny, the default, or EffectAllow. Only rules that deny may specify a
+	// replacement, a severity, deadlines or a message.
+	Effect      string `yaml:"effect"`
 	Replacement string `yaml:"replacement"`
+	Rewrite     string `yaml:"rewrite"`
+
+	// AllowedIn and DeniedIn are comma-separated lists of package paths or patterns that are matched
+	// against the analysed package. Only the packages in AllowedIn may use what the rule forbids, e.g
+	// 'database/sql' in 'pkg/platform/db', while DeniedIn restricts the rule to the listed packages.
+	// For rules that allow packages AllowedIn restricts the packages that may use the allowed package
+	// while DeniedIn excludes packages from using it.
+	AllowedIn string `yaml:"allowed_in"`
+	DeniedIn  string `yaml:"denied_in"`
+
+	// Severity of the findings of the rule which defaults to SeverityError.
+	Severity string `yaml:"severity"`
+
+	// DisabledUntil, WarnFrom and ErrorFrom are the dates at which the rule takes effect, after which
+	// its findings are respectively reported as information, warnings and errors. They take precedence
+	// over the Severity.
+	DisabledUntil string `yaml:"disabled_until"`
+	WarnFrom      string `yaml:"warn_from"`
+	ErrorFrom     string `yaml:"error_from"`
+
+	// Message explains why the rule exists and replaces the default description of its findings. It
+	// may reference the '$symbol', '$replacement' and '$importer' placeholders. URL links to further
+	// documentation such as a migration guide and is appended to the description.
+	Message string `yaml:"message"`
+	URL     string `yaml:"url"`
+
+	loc       location            // Location of the rule in its configuration file, if any.
+	keys      map[string]location // Locations of the values of the keys of the rule.
+	inherited int                 // Number of parent or extended configurations that the rule comes through.
 }
 
+// Accepted values for PackageRule.Rewrite which determine how suggested fixes handle the local name
+// of a replaced import. By default an alias is added so that the original name keeps on working.
+const (
+	rewriteAlias     = "alias"
+	rewriteSelectors = "selectors"
+)
+
 type Symbols struct {
-	Whitelist bool         `yaml:"whitelist"`
-	Rules     []SymbolRule `yaml:"rules"`
+	// Whitelist gives the rules EffectAllow by default and denies all the symbols that no rule matches,
+	// except for methods and fields, as a '{id: symbols-whitelist, package: "...", names: "*", effect:
+	// deny}' rule would.
+	Whitelist bool `yaml:"whitelist"`
+	// Scoped restricts the symbols that are denied for not being matched by any rule to those of the
+	// packages of which some symbols are allowed. All other packages remain fully usable. It stands for a
+	// '{package: <package>, names: "*", effect: deny}' rule for each package of which a rule allows symbols,
+	// which is in effect in the importers in which the rule allows them.
+	Scoped bool         `yaml:"scoped"`
+	Rules  []SymbolRule `yaml:"rules"`
 }
 
 type SymbolRule struct {
-	Package            string `yaml:"package"`
+	// ID identifies the rule as for PackageRule. It defaults to the package, or the receiver type, and each
+	// of the names or the regular expression of the rule, e.g 'os.Exit' or '(database/sql.DB).Query'. A
+	// regular expression that contains commas requires an explicit ID.
+	ID      string `yaml:"id"`
+	Package string `yaml:"package"`
+	// Effect of the rule as for PackageRule. Rules that allow methods or fields are not supported.
+	Effect string `yaml:"effect"`
+	// Receiver restricts the rule to the methods and fields of the named type, e.g '*DB' or 'DB' for
+	// the methods of database/sql.DB. Whether the type is written as a pointer is irrelevant.
+	Receiver string `yaml:"receiver"`
+	// Name lists the names of the symbols, each of which may be a glob such as 'Must*'. Alternatively
+	// Regex matches the names of the symbols with a regular expression, e.g '^(Print|Fatal).*'. The
+	// ReplacementName may refer to the capture groups of the expression, or to the wildcards of a glob,
+	// with '$1' or '${name}'.
 	Name               string `yaml:"names"`
+	Regex              string `yaml:"regex"`
 	ReplacementPackage string `yaml:"replacement_package"`
 	ReplacementName    string `yaml:"replacement_name"`
+
+	// Call and Template describe how calls to the symbol should be rewritten. Arguments of calls that
+	// match the Call pattern, e.g 'errors.Wrap($err, $msg)', are bound to the named placeholders which
+	// are then substituted in the Template, e.g 'fmt.Errorf("$msg: %w", $err)'. The template may only
+	// reference the ReplacementPackage, by any name such as 'yaml' for 'gopkg.in/yaml.v3'.
+	Call     string `yaml:"call"`
+	Template string `yaml:"template"`
+
+	// AllowedIn and DeniedIn restrict the analysed packages to which the rule applies, as for
+	// PackageRule.
+	AllowedIn string `yaml:"allowed_in"`
+	DeniedIn  string `yaml:"denied_in"`
+
+	// Severity of the findings of the rule which defaults to SeverityError.
+	Severity string `yaml:"severity"`
+
+	// DisabledUntil, WarnFrom and ErrorFrom are the dates at which the rule takes effect, as for
+	// PackageRule.
+	DisabledUntil string `yaml:"disabled_until"`
+	WarnFrom      string `yaml:"warn_from"`
+	ErrorFrom     string `yaml:"error_from"`
+
+	// Message and URL document the rule, as for PackageRule.
+	Message string `yaml:"message"`
+	URL     string `yaml:"url"`
+
+	loc       location            // Location of the rule in its configuration file, if any.
+	keys      map[string]location // Locations of the values of the keys of the rule.
+	inherited int                 // Number of parent or extended configurations that the rule comes through.
+}
+
+// Rules either deny or allow what they match. When several rules match the same package or symbol the
+// most specific one decides, in the following order:
+//
+//   - Symbol rules take precedence over package rules. When some symbols of a denied package are
+//     allowed the package rules apply to the uses of its other symbols rather than to its import. This
+//     does not hold for rules that allow the symbols of a class of packages with a selector.
+//   - Rules that list a package or symbol exactly take precedence over those that match it with a
+//     pattern, as described for package and name patterns.
+//   - Rules of a nested configuration, or of a file that extends another, take precedence over the rules
+//     of the parent or extended configuration that match the same package or symbol.
+//
+// A rule has the opposite effect in the importers that its AllowedIn and DeniedIn exclude. Packages and
+// symbols that no rule matches are allowed, so an allow-list starts with a rule that denies everything,
+// e.g '{path: "...", effect: deny}' or '{package: "...", names: "*", effect: deny}', which is what the
+// Whitelist of packages and symbols adds.
+const (
+	EffectDeny  = "deny"
+	EffectAllow = "allow"
+)
+
+// Accepted values for the severity of rules which are set as the Category of their diagnostics. Only
+// errors are meant to fail a build which allows new rules to be introduced as warnings first.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+func validSeverity(severity string) bool {
+	return severity == SeverityError || severity == SeverityWarning || severity == SeverityInfo
 }
 
 var configPath string
 
-func getConfig(c *Configuration) *Configuration {
-	if c != nil {
-		return c
-	} else if configPath == "" {
-		log.Fatal("Need to specify a configuration.")
+func (c *Configuration) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// LoadConfiguration reads and parses the configuration file at the given path, together with the files
+// that it extends or includes. Errors that concern a specific file are returned as a *ConfigError.
+func LoadConfiguration(path string) (*Configuration, error) {
+	l := &loader{loading: map[string]bool{}}
+	return l.load(path)
+}
+
+// Validate checks that the configuration is valid without otherwise using it. All the problems of the
+// configuration are returned at once as ConfigErrors.
+func (c *Configuration) Validate() error {
+	_, err := c.validate()
+	return err
+}
+
+// ConfigError is an error in a configuration file. Line and Column locate the error within the file and
+// are zero when the error does not concern a specific part of it.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	if e.File == "" {
+		return e.Err.Error()
+	}
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %v", pos, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors lists all the errors that were found while validating a configuration, ordered by their
+// location.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ConfigErrors) add(err error) {
+	switch err := err.(type) {
+	case nil:
+	case ConfigErrors:
+		*e = append(*e, err...)
+	case *ConfigError:
+		*e = append(*e, err)
+	default:
+		*e = append(*e, &ConfigError{Err: err})
+	}
+}
+
+func (e ConfigErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	sort.SliceStable(e, func(i, j int) bool {
+		switch {
+		case e[i].File != e[j].File:
+			return e[i].File < e[j].File
+		case e[i].Line != e[j].Line:
+			return e[i].Line < e[j].Line
+		case e[i].Column != e[j].Column:
+			return e[i].Column < e[j].Column
+		default:
+			return e[i].Err.Error() < e[j].Err.Error()
+		}
+	})
+	return e
+}
+
+// location locates a rule, or the value of one of its keys, within a configuration file.
+type location struct {
+	file         string
+	line, column int
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.file, l.line, l.column)
+}
+
+func (l location) errorf(format string, args ...interface{}) *ConfigError {
+	return &ConfigError{File: l.file, Line: l.line, Column: l.column, Err: fmt.Errorf(format, args...)}
+}
+
+// keyLocation returns the location of the value of the given key, or that of the rule if the key is
+// not set.
+func keyLocation(loc location, keys map[string]location, key string) location {
+	if l, ok := keys[key]; ok {
+		return l
 	}
+	return loc
+}
 
-	raw, err := ioutil.ReadFile(configPath)
+// Precedence validates the configuration and describes, for each pair of rules that may match the
+// same package or symbol, which of the two rules takes precedence.
+func (c *Configuration) Precedence() ([]string, error) {
+	config, err := c.validate()
 	if err != nil {
-		log.Fatalf("Unable to read the specified configuration at %q: %v", configPath, err)
+		return nil, err
+	}
+
+	var precedence []string
+	var packages []string
+	for pkg := range config.packages {
+		packages = append(packages, pkg)
+	}
+	for _, overlap := range overlaps(packages) {
+		precedence = append(precedence, fmt.Sprintf("package rule %s takes precedence over %s", overlap[0], overlap[1]))
 	}
 
-	c = &Configuration{}
-	if err = yaml.Unmarshal(raw, c); err != nil {
-		log.Fatalf("The configuration in %q could not be parsed: %v", configPath, err)
+	symbols := map[string][]string{} // Symbol relative to its package -> packages.
+	for symbol := range config.symbols {
+		pkg := symbolPackage(symbol)
+		relative := strings.Replace(symbol, pkg, "", 1)
+		symbols[relative] = append(symbols[relative], pkg)
+	}
+	for relative, packages := range symbols {
+		for _, overlap := range overlaps(packages) {
+			precedence = append(precedence, fmt.Sprintf(
+				"symbol rule %s takes precedence over %s",
+				strings.Replace(relative, ".", overlap[0]+".", 1),
+				strings.Replace(relative, ".", overlap[1]+".", 1),
+			))
+		}
 	}
-	return c
+	for idx, p := range config.symbolNames {
+		for symbol := range config.symbols {
+			if i := strings.LastIndex(symbol, "."); symbol[:i] == p.owner && p.re.MatchString(symbol[i+1:]) {
+				precedence = append(precedence, fmt.Sprintf("symbol rule %s takes precedence over %s", symbol, p))
+			}
+		}
+		for _, prev := range config.symbolNames[:idx] {
+			if prev.owner == p.owner {
+				precedence = append(precedence, fmt.Sprintf("symbol rule %s takes precedence over %s", prev, p))
+			}
+		}
+	}
+	sort.Strings(precedence)
+	return precedence, nil
 }
 
 type configuration struct {
-	packages          map[string]string
-	whitelistPackages bool
+	packages        map[string]target
+	packagePatterns []string // Package patterns in order of decreasing precedence.
+
+	symbols        map[string]target
+	symbolNames    []namePattern // Rules that match symbol names with patterns, in order of precedence.
+	symbolPatterns []string      // Package patterns of symbols in order of decreasing precedence.
+
+	indirectTypes bool
+
+	mainModule string // Path of the module of the analysed package, if any. Only set by runs.
+
+	now      time.Time     // Time at which the deadlines of rules are evaluated.
+	selected ruleSelection // Rules that are enabled for the current run.
+}
+
+// target describes whether a package or symbol that is matched by a rule is allowed and, if not, what it
+// should be replaced with.
+type target struct {
+	allow            bool
+	replacement      string
+	rewriteSelectors bool
+	template         *callTemplate
+	scope            scope
+	severity         string
+	deadlines        deadlines
+	message          string
+	url              string
+	id               string    // ID of the rule that the target was expanded from.
+	loc              location  // Location of the rule in its configuration file, if any.
+	inherited        int       // Number of parent or extended configurations that the rule comes through.
+	forbiddenFrom    time.Time // Upcoming date from which the rule is an error. Only set by lookups.
+}
 
-	symbols          map[string]string
-	whitelistSymbols bool
+// scope describes the importers in which a rule is in effect.
+type scope struct {
+	only   []string // Importers to which the rule is restricted, if any.
+	except []string // Importers in which the rule is not in effect.
+}
+
+// expandScope expands the importers of a rule into its scope. Errors are returned together with the key
+// of the field that contains them.
+func expandScope(allowedIn string, deniedIn string, allow bool) (scope, string, error) {
+	var s scope
+	for _, spec := range []struct {
+		key   string
+		line  string
+		field *[]string
+	}{
+		{"allowed_in", allowedIn, &s.except},
+		{"denied_in", deniedIn, &s.only},
+	} {
+		if spec.line == "" {
+			continue
+		}
+		patterns, err := expandLine(spec.line)
+		if err != nil {
+			return scope{}, spec.key, err
+		}
+		for _, pattern := range patterns {
+			if err = validatePattern(pattern); err != nil {
+				return scope{}, spec.key, err
+			}
+		}
+		*spec.field = patterns
+	}
+	if allow {
+		s.only, s.except = s.except, s.only
+	}
+	return s, "", nil
+}
+
+// includes reports whether the rule is in effect within the given importer, matching the importer
+// against the patterns of the scope with the given function.
+func (s scope) includes(importer string, match func(pattern string, pkg string) bool) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if match(pattern, importer) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(s.only) == 0 || matches(s.only)) && !matches(s.except)
 }
 
 func (c *Configuration) validate() (*configuration, error) {
 	var err error
-	config := &configuration{
-		whitelistPackages: c.Packages.Whitelist,
-		whitelistSymbols:  c.Symbols.Whitelist,
-	}
+	config := &configuration{indirectTypes: c.IndirectTypes}
+	packageRules, symbolRules := c.rules()
 
-	config.packages, err = expandPackageRules(c.Packages.Rules, c.Packages.Whitelist)
-	if err != nil {
+	// All the rules are validated so that every problem is reported at once.
+	var errs ConfigErrors
+	errs.add(c.validateIDs())
+
+	config.packages, err = expandPackageRules(packageRules)
+	errs.add(err)
+
+	config.symbols, config.symbolNames, err = expandSymbolRules(symbolRules)
+	errs.add(err)
+
+	// Inconsistencies between rules are only meaningful when the rules themselves are valid.
+	if err = errs.err(); err != nil {
 		return nil, err
 	}
 
-	config.symbols, err = expandSymbolRules(c.Symbols.Rules, c.Symbols.Whitelist)
-	if err != nil {
-		return nil, err
+	var keys []string
+	for pkg := range config.packages {
+		keys = append(keys, pkg)
 	}
+	config.packagePatterns = sortedPatterns(keys)
 
-	if err = checkInconsistencies(config); err != nil {
-		return nil, err
+	keys = keys[:0]
+	for symbol := range config.symbols {
+		keys = append(keys, symbolPackage(symbol))
+	}
+	for _, p := range config.symbolNames {
+		keys = append(keys, symbolPackage(p.owner+"."))
+	}
+	config.symbolPatterns = sortedPatterns(keys)
+
+	// Whether replacements are denied may depend on the main module, in which case runs check them.
+	if !config.dependsOnModule() {
+		if err = checkInconsistencies(config); err != nil {
+			return nil, err
+		}
 	}
 
 	return config, nil
 }
 
+// rules returns the rules of the configuration together with the rules that its options stand for.
+func (c *Configuration) rules() ([]PackageRule, []SymbolRule) {
+	packages := append([]PackageRule{}, c.Packages.Rules...)
+	symbols := append([]SymbolRule{}, c.Symbols.Rules...)
+	if c.Packages.Whitelist {
+		for idx := range packages {
+			if packages[idx].Effect == "" {
+				packages[idx].Effect = EffectAllow
+			}
+		}
+	}
+	if c.Symbols.Whitelist {
+		for idx := range symbols {
+			if symbols[idx].Effect == "" {
+				symbols[idx].Effect = EffectAllow
+			}
+		}
+	}
+
+	for _, trusted := range []struct {
+		selector string
+		allowed  bool
+	}{
+		{"@std", c.AllowStd},
+		{"@main-module", c.AllowMainModule},
+	} {
+		if !trusted.allowed {
+			continue
+		}
+		packages = appendPackageRule(packages, PackageRule{Path: trusted.selector, Effect: EffectAllow})
+		if c.Symbols.Whitelist && !c.Symbols.Scoped {
+			symbols = appendSymbolRule(symbols, SymbolRule{Package: trusted.selector, Name: "*", Effect: EffectAllow})
+		}
+	}
+	if c.Symbols.Scoped {
+		for _, r := range scopedRules(symbols) {
+			symbols = appendSymbolRule(symbols, r)
+		}
+	}
+
+	if c.Packages.Whitelist {
+		packages = appendPackageRule(packages, PackageRule{ID: "packages-whitelist", Path: "...", Effect: EffectDeny})
+	}
+	if c.Symbols.Whitelist && !c.Symbols.Scoped {
+		symbols = appendSymbolRule(symbols, SymbolRule{ID: "symbols-whitelist", Package: "...", Name: "*", Effect: EffectDeny})
+	}
+	return packages, symbols
+}
+
+// scopedRules returns the rules that deny the other symbols of the packages of which the given rules
+// allow symbols, in the importers in which they are allowed.
+func scopedRules(rules []SymbolRule) []SymbolRule {
+	var scoped []SymbolRule
+	packages := map[string]int{}
+	for _, r := range rules {
+		if r.Effect != EffectAllow || isSelector(r.Package) {
+			continue
+		}
+		// The importers in which a rule that allows symbols is in effect are those that a rule that denies
+		// them lists the other way around.
+		deny := SymbolRule{Package: r.Package, Name: "*", Effect: EffectDeny, AllowedIn: r.DeniedIn, DeniedIn: r.AllowedIn}
+		idx, ok := packages[r.Package]
+		if !ok {
+			packages[r.Package] = len(scoped)
+			scoped = append(scoped, deny)
+			continue
+		}
+		// When symbols of the same package are allowed in different importers the other symbols are denied
+		// in all of them, or in all importers if their importers can not be combined.
+		prev := &scoped[idx]
+		switch {
+		case prev.AllowedIn == deny.AllowedIn && prev.DeniedIn == deny.DeniedIn:
+		case prev.AllowedIn == "" && deny.AllowedIn == "" && prev.DeniedIn != "" && deny.DeniedIn != "":
+			prev.DeniedIn += "," + deny.DeniedIn
+		default:
+			prev.AllowedIn, prev.DeniedIn = "", ""
+		}
+	}
+	return scoped
+}
+
+// appendPackageRule appends the rule unless a rule already lists the same packages.
+func appendPackageRule(rules []PackageRule, rule PackageRule) []PackageRule {
+	for _, r := range rules {
+		if r.Path == rule.Path {
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// appendSymbolRule appends the rule unless a rule already lists the same symbols.
+func appendSymbolRule(rules []SymbolRule, rule SymbolRule) []SymbolRule {
+	for _, r := range rules {
+		if r.Package == rule.Package && r.Receiver == "" && r.Name == rule.Name && r.Regex == "" {
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// packageTarget returns the target of the most specific package rule that matches the given package.
+func (c *configuration) packageTarget(pkg string) (target, bool) {
+	if t, ok := c.packages[pkg]; ok {
+		return t, true
+	}
+	for _, pattern := range c.packagePatterns {
+		if c.match(pattern, pkg) {
+			t := c.packages[pattern]
+			if t.replacement != "" {
+				t.replacement = expandSubtree(pattern, t.replacement, pkg)
+			}
+			return t, true
+		}
+	}
+	return target{}, false
+}
+
+// symbolTarget returns the target of the most specific symbol rule that matches a symbol of the given
+// package. The symbol function formats the symbol relative to a package path or pattern.
+func (c *configuration) symbolTarget(pkg string, symbol func(pkg string) string) (target, bool) {
+	if t, ok := c.symbolRule(symbol(pkg)); ok {
+		return t, true
+	}
+	for _, pattern := range c.symbolPatterns {
+		if !c.match(pattern, pkg) {
+			continue
+		}
+		t, ok := c.symbolRule(symbol(pattern))
+		if !ok {
+			continue
+		}
+		if t.replacement != "" {
+			replPkg := symbolPackage(t.replacement)
+			if replPkg == pattern {
+				t.replacement = strings.Replace(t.replacement, replPkg, pkg, 1)
+			} else {
+				t.replacement = strings.Replace(t.replacement, replPkg, expandSubtree(pattern, replPkg, pkg), 1)
+			}
+		}
+		return t, true
+	}
+	return target{}, false
+}
+
+// packageTargetIn returns the target of the package rule that applies to the given package and whether
+// it denies the package within the given importer at the current time.
+func (c *configuration) packageTargetIn(importer string, pkg string) (target, bool) {
+	t, ok := c.packageTarget(pkg)
+	if !ok {
+		return target{}, false
+	}
+	return c.decide(t, importer)
+}
+
+// symbolTargetIn returns the target of the symbol rule that applies to the given symbol and whether it
+// denies the symbol within the given importer at the current time. The last result reports whether a
+// symbol rule matched at all, as the package rules decide otherwise.
+func (c *configuration) symbolTargetIn(importer string, pkg string, symbol func(pkg string) string) (target, bool, bool) {
+	t, ok := c.symbolTarget(pkg, symbol)
+	if !ok {
+		return target{}, false, false
+	}
+	t, denied := c.decide(t, importer)
+	return t, denied, true
+}
+
+// decide reports whether the rule of the target denies its subject within the given importer. Rules
+// have the opposite effect outside of their scope while rules that deny are only in effect when they
+// are selected and their deadlines have passed.
+func (c *configuration) decide(t target, importer string) (target, bool) {
+	switch {
+	case t.allow:
+		return t, !t.scope.includes(importer, c.match)
+	case !t.scope.includes(importer, c.match) || !c.selected.includes(t.id):
+		return t, false
+	default:
+		return t.deadlines.apply(t, c.now)
+	}
+}
+
+// allowsSymbolsOf reports whether symbol rules allow some of the symbols of the given package within the
+// given importer, in which case the package rules apply to the uses of its other symbols instead of to
+// its import.
+func (c *configuration) allowsSymbolsOf(importer string, pkg string) bool {
+	allows := func(owner string, t target) bool {
+		return t.allow && !isSelector(owner) && t.scope.includes(importer, c.match) && c.match(owner, pkg)
+	}
+	for symbol, t := range c.symbols {
+		if allows(symbolPackage(symbol), t) {
+			return true
+		}
+	}
+	for _, p := range c.symbolNames {
+		if allows(symbolPackage(p.owner+"."), p.base) {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolRule returns the target of the rule that applies to the given symbol, preferring rules that
+// list the symbol's name literally over those that match it with a pattern.
+func (c *configuration) symbolRule(symbol string) (target, bool) {
+	if t, ok := c.symbols[symbol]; ok {
+		return t, true
+	}
+	idx := strings.LastIndex(symbol, ".")
+	owner, name := symbol[:idx], symbol[idx+1:]
+	for _, p := range c.symbolNames {
+		if p.owner == owner && p.re.MatchString(name) {
+			return p.target(name), true
+		}
+	}
+	return target{}, false
+}
+
+// checkInconsistencies checks that symbols are not replaced with symbols of packages that are denied, or
+// that differ from the replacement of their own package.
 func checkInconsistencies(c *configuration) error {
-	pkgMap := map[string]bool{}
-	for p := range c.packages {
-		pkgMap[p] = true
+	rules := map[string]target{}
+	for source, t := range c.symbols {
+		rules[source] = t
+	}
+	for _, p := range c.symbolNames {
+		t := p.base
+		if p.replacementPkg != "" {
+			t.replacement = p.replacementPkg + "." + p.replacementName
+		}
+		rules[p.String()] = t
 	}
 
-	for source, target := range c.symbols {
+	var errs ConfigErrors
+	for source, t := range rules {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, t.loc.errorf(format, args...))
+		}
+		target := t.replacement
 		var sourcePkg, targetPkg string
-		sourcePkg = source[:strings.LastIndex(source, ".")]
+		sourcePkg = symbolPackage(source)
 		if target != "" {
-			targetPkg = target[:strings.LastIndex(target, ".")]
+			targetPkg = symbolPackage(target)
+		} else if t.template != nil {
+			target, targetPkg = t.template.raw, t.template.pkg
 		}
 
-		if c.whitelistPackages {
-			if c.whitelistSymbols && !pkgMap[sourcePkg] {
-				return fmt.Errorf("cannot whitelist symbol %s as %s is not whitelisted in the package rules", source, sourcePkg)
-			} else if !c.whitelistSymbols && targetPkg != "" && !pkgMap[targetPkg] {
-				return fmt.Errorf("cannot replace %s with %s as %s is not whitelisted in the package rules", source, target, targetPkg)
-			}
-		} else {
-			if c.whitelistSymbols && pkgMap[sourcePkg] {
-				return fmt.Errorf("cannot whitelist symbol %s as %s is blacklisted in the package rules", source, sourcePkg)
-			} else if !c.whitelistSymbols && targetPkg != "" && pkgMap[targetPkg] {
-				return fmt.Errorf("cannot replace %s with %s as %s is blacklisted in the package rules", source, target, targetPkg)
-			}
+		if targetPkg == "" {
+			continue
+		}
+		if pt, ok := c.packageTarget(targetPkg); ok && !pt.allow {
+			fail("cannot replace %s with %s in %s as %s is denied by %s", source, target, t.describe(), targetPkg, pt.describe())
 		}
 
-		if !c.whitelistPackages && !c.whitelistSymbols {
-			if replPkg := c.packages[sourcePkg]; replPkg != "" && targetPkg != "" && replPkg != targetPkg {
-				return fmt.Errorf("cannot replace %s with %s as %s is replaced with %s in the package rules", source, target, sourcePkg, replPkg)
-			}
+		if pt, _ := c.packageTarget(sourcePkg); pt.replacement != "" && pt.replacement != targetPkg {
+			fail(
+				"cannot replace %s with %s in %s as %s is replaced with %s in %s",
+				source,
+				target,
+				t.describe(),
+				sourcePkg,
+				pt.replacement,
+				pt.describe(),
+			)
 		}
 	}
-	return nil
+	return errs.err()
+}
+
+// describe identifies the rule of the target in errors, together with its location.
+func (t target) describe() string {
+	switch {
+	case t.loc.file != "":
+		return fmt.Sprintf("rule %q at %s", t.id, t.loc)
+	case t.id != "":
+		return fmt.Sprintf("rule %q", t.id)
+	default:
+		return "an unnamed rule"
+	}
 }
 
-func expandPackageRules(rules []PackageRule, whitelist bool) (map[string]string, error) {
-	expanded := map[string]string{}
+func expandPackageRules(rules []PackageRule) (map[string]target, error) {
+	expanded := map[string]target{}
+	var errs ConfigErrors
 	for _, r := range rules {
-		if whitelist && r.Replacement != "" {
-			return nil, fmt.Errorf("package rule %+v can not specify a replacement as packages are being whitelisted", r)
-		}
+		errs.add(expandPackageRule(r, expanded))
+	}
+	return expanded, errs.err()
+}
+
+func expandPackageRule(r PackageRule, expanded map[string]target) error {
+	allow := r.Effect == EffectAllow
+	switch {
+	case r.Effect != "" && r.Effect != EffectAllow && r.Effect != EffectDeny:
+		return r.errorf("effect", "has an unknown effect, expected %q or %q", EffectAllow, EffectDeny)
+	case allow && r.Replacement != "":
+		return r.errorf("replacement", "can not specify a replacement for a rule that allows packages")
+	case r.Rewrite != "" && r.Rewrite != rewriteAlias && r.Rewrite != rewriteSelectors:
+		return r.errorf("rewrite", "has an unknown rewrite mode, expected %q or %q", rewriteAlias, rewriteSelectors)
+	case r.Rewrite != "" && r.Replacement == "":
+		return r.errorf("rewrite", "can not specify a rewrite mode without a replacement")
+	case r.Severity != "" && !validSeverity(r.Severity):
+		return r.errorf("severity", "has an unknown severity, expected %q, %q or %q", SeverityError, SeverityWarning, SeverityInfo)
+	case r.Severity != "" && allow:
+		return r.errorf("severity", "can not specify a severity for a rule that allows packages")
+	case (r.DisabledUntil != "" || r.WarnFrom != "" || r.ErrorFrom != "") && allow:
+		return r.errorf("", "can not specify deadlines for a rule that allows packages")
+	case r.Severity != "" && (r.WarnFrom != "" || r.ErrorFrom != ""):
+		return r.errorf("", "can not specify both a severity and deadlines")
+	case (r.Message != "" || r.URL != "") && allow:
+		return r.errorf("", "can not specify a message or URL for a rule that allows packages")
+	}
+
+	if err := validateMessage(r.Message); err != nil {
+		return r.errorf("message", "contained an error in its message: %s", err)
+	}
 
-		packages, err := expandLine(r.Path)
+	d, err := parseDeadlines(r.DisabledUntil, r.WarnFrom, r.ErrorFrom)
+	if err != nil {
+		return r.errorf("", "contained an error in its deadlines: %s", err)
+	}
+
+	packages, err := expandLine(r.Path)
+	if err != nil {
+		return r.errorf("path", "contained an error in its path: %s", err)
+	}
+
+	s, key, err := expandScope(r.AllowedIn, r.DeniedIn, allow)
+	if err != nil {
+		return r.errorf(key, "contained an error in its importers: %s", err)
+	}
+	base := target{
+		allow:     allow,
+		inherited: r.inherited,
+		scope:     s,
+		severity:  r.Severity,
+		deadlines: d,
+		message:   r.Message,
+		url:       r.URL,
+		id:        r.id(),
+		loc:       r.loc,
+	}
+
+	var replacements []string
+	if r.Replacement != "" {
+		replacements, err = expandLine(r.Replacement)
 		if err != nil {
-			return nil, fmt.Errorf("package rule %+v contained an error in its path: %s", r, err)
+			return r.errorf("replacement", "contained an error in its replacement: %s", err)
+		} else if len(replacements) != len(packages) {
+			return r.errorf("replacement", "has a mismatched number of replacement specifications")
 		}
+	}
 
-		var replacements []string
-		if r.Replacement != "" {
-			replacements, err = expandLine(r.Replacement)
-			if err != nil {
-				return nil, fmt.Errorf("package rule %+v contained an error in its replacement: %s", r, err)
-			} else if len(replacements) != len(packages) {
-				return nil, fmt.Errorf("package rule %+v has a mismatched number of replacement specifications", r)
+	for idx := 0; idx < len(packages); idx++ {
+		if err = validatePattern(packages[idx]); err != nil {
+			return r.errorf("path", "contained an error in its path: %s", err)
+		}
+		if len(replacements) > 0 {
+			if err = validatePattern(replacements[idx]); err != nil {
+				return r.errorf("replacement", "contained an error in its replacement: %s", err)
+			} else if err = validateReplacementPattern(packages[idx], replacements[idx]); err != nil {
+				return r.errorf("replacement", "contained an error in its replacement: %s", err)
 			}
 		}
 
-		for idx := 0; idx < len(packages); idx++ {
-			var target string
-			if len(replacements) > 0 {
-				target = replacements[idx]
-			}
-			expanded[packages[idx]] = target
+		t := base
+		t.rewriteSelectors = r.Rewrite == rewriteSelectors
+		if r.ID == "" {
+			t.id = packages[idx]
+		}
+		if len(replacements) > 0 {
+			t.replacement = replacements[idx]
+		}
+		if err = addTarget(expanded, packages[idx], t); err != nil {
+			return r.errorf("path", "%v", err)
 		}
 	}
-	return expanded, nil
+	return nil
 }
 
-func expandSymbolRules(rules []SymbolRule, whitelist bool) (map[string]string, error) {
-	expanded := map[string]string{}
+func expandSymbolRules(rules []SymbolRule) (map[string]target, []namePattern, error) {
+	expanded := map[string]target{}
+	var patterns []namePattern
+	var errs ConfigErrors
 	for _, r := range rules {
-		switch {
-		case r.Package == "":
-			return nil, fmt.Errorf("symbol rule %+v is missing a package path", r)
-		case strings.Count(r.Package, ",") > 0:
-			return nil, fmt.Errorf("symbol rule %+v specifies multiple packages which is not supported", r)
-		case strings.Count(r.ReplacementPackage, ",") > 0:
-			return nil, fmt.Errorf("symbol rule %+v specifies multiple packages as replacement which is not supported", r)
-		case whitelist && (r.ReplacementPackage != "" || r.ReplacementName != ""):
-			return nil, fmt.Errorf("symbol rule %+v can not specify a replacement as packages are being whitelisted", r)
-		}
+		errs.add(expandSymbolRule(r, expanded, &patterns))
+	}
+	return expanded, patterns, errs.err()
+}
 
-		symbols, err := expandLine(r.Name)
-		if err != nil {
-			return nil, fmt.Errorf("symbol rule %+v contained an error in its name: %s", r, err)
+func expandSymbolRule(r SymbolRule, expanded map[string]target, patterns *[]namePattern) error {
+	allow := r.Effect == EffectAllow
+	switch {
+	case r.Effect != "" && r.Effect != EffectAllow && r.Effect != EffectDeny:
+		return r.errorf("effect", "has an unknown effect, expected %q or %q", EffectAllow, EffectDeny)
+	case r.Package == "":
+		return r.errorf("", "is missing a package path")
+	case strings.Count(r.Package, ",") > 0:
+		return r.errorf("", "specifies multiple packages which is not supported")
+	case strings.Count(r.ReplacementPackage, ",") > 0:
+		return r.errorf("", "specifies multiple packages as replacement which is not supported")
+	case allow && (r.ReplacementPackage != "" || r.ReplacementName != "" || r.Template != ""):
+		return r.errorf("replacement", "can not specify a replacement for a rule that allows symbols")
+	case r.Name != "" && r.Regex != "":
+		return r.errorf("regex", "can not specify both names and a regular expression")
+	case (r.Call == "") != (r.Template == ""):
+		return r.errorf("", "needs to specify both a call pattern and a template")
+	case r.Template != "" && r.ReplacementName != "":
+		return r.errorf("template", "can not specify both a replacement name and a template")
+	case r.Template != "" && r.Regex != "":
+		return r.errorf("template", "can not specify a template for a regular expression")
+	case r.Receiver != "" && !token.IsIdentifier(strings.TrimPrefix(r.Receiver, "*")):
+		return r.errorf("receiver", "does not specify a valid receiver type")
+	case r.Receiver != "" && allow:
+		return r.errorf("", "can not allow methods or fields")
+	case r.Receiver != "" && (r.ReplacementPackage != "" || r.Template != ""):
+		return r.errorf("", "can only replace methods or fields with another name")
+	case r.Severity != "" && !validSeverity(r.Severity):
+		return r.errorf("severity", "has an unknown severity, expected %q, %q or %q", SeverityError, SeverityWarning, SeverityInfo)
+	case r.Severity != "" && allow:
+		return r.errorf("severity", "can not specify a severity for a rule that allows symbols")
+	case (r.DisabledUntil != "" || r.WarnFrom != "" || r.ErrorFrom != "") && allow:
+		return r.errorf("", "can not specify deadlines for a rule that allows symbols")
+	case r.Severity != "" && (r.WarnFrom != "" || r.ErrorFrom != ""):
+		return r.errorf("", "can not specify both a severity and deadlines")
+	case (r.Message != "" || r.URL != "") && allow:
+		return r.errorf("", "can not specify a message or URL for a rule that allows symbols")
+	}
+
+	if err := validateMessage(r.Message); err != nil {
+		return r.errorf("message", "contained an error in its message: %s", err)
+	}
+
+	d, err := parseDeadlines(r.DisabledUntil, r.WarnFrom, r.ErrorFrom)
+	if err != nil {
+		return r.errorf("", "contained an error in its deadlines: %s", err)
+	}
+
+	if err = validatePattern(r.Package); err != nil {
+		return r.errorf("package", "contained an error in its package: %s", err)
+	} else if err = validateReplacementPattern(r.Package, r.ReplacementPackage); err != nil {
+		return r.errorf("replacement_package", "contained an error in its replacement: %s", err)
+	}
+	if r.ReplacementPackage != "" {
+		if err = validatePattern(r.ReplacementPackage); err != nil {
+			return r.errorf("replacement_package", "contained an error in its replacement: %s", err)
 		}
+	}
+
+	s, key, err := expandScope(r.AllowedIn, r.DeniedIn, allow)
+	if err != nil {
+		return r.errorf(key, "contained an error in its importers: %s", err)
+	}
+	base := target{
+		allow:     allow,
+		inherited: r.inherited,
+		scope:     s,
+		severity:  r.Severity,
+		deadlines: d,
+		message:   r.Message,
+		url:       r.URL,
+		id:        r.id(),
+		loc:       r.loc,
+	}
 
-		var replacements []string
+	// Regular expressions are taken as is as they may contain braces and commas of their own.
+	symbols, replacements := []string{r.Regex}, []string{r.ReplacementName}
+	if r.Regex == "" {
+		if symbols, err = expandLine(r.Name); err != nil {
+			return r.errorf("names", "contained an error in its name: %s", err)
+		}
 		if r.ReplacementName != "" {
-			replacements, err = expandLine(r.ReplacementName)
-			if err != nil {
-				return nil, fmt.Errorf("symbol rule %+v contained an error in its replacement: %s", r, err)
-			} else if len(replacements) != len(symbols) {
-				return nil, fmt.Errorf("symbol rule %+v has a mismatched number of replacement specifications", r)
+			if replacements, err = expandLine(r.ReplacementName); err != nil {
+				return r.errorf("replacement_name", "contained an error in its replacement: %s", err)
 			}
 		}
+	}
+	if r.ReplacementName == "" {
+		replacements = nil
+	} else if len(replacements) != len(symbols) {
+		return r.errorf("replacement_name", "has a mismatched number of replacement specifications")
+	}
+	for idx, symbol := range symbols {
+		if r.Regex == "" && !isNamePattern(symbol) && !token.IsIdentifier(symbol) {
+			return r.errorf("names", "contains %q which is not a valid identifier", symbol)
+		}
+		if len(replacements) > 0 && !strings.Contains(replacements[idx], "$") && !token.IsIdentifier(replacements[idx]) {
+			return r.errorf("replacement_name", "contains replacement %q which is not a valid identifier", replacements[idx])
+		}
+	}
+
+	if r.Template != "" {
+		if len(symbols) != 1 || isNamePattern(symbols[0]) {
+			return r.errorf("template", "can only specify a template for a single name")
+		}
+		tmpl, err := parseCallTemplate(r.Call, r.Template, r.ReplacementPackage, symbols[0])
+		if err != nil {
+			return r.errorf("template", "contained an error in its template: %s", err)
+		}
+		t := base
+		t.template = tmpl
+		t.id = r.expandedID(symbols[0])
+		if err = addTarget(expanded, r.Package+"."+symbols[0], t); err != nil {
+			return r.errorf("names", "%v", err)
+		}
+		return nil
+	}
+
+	owner := r.owner()
+
+	var targetPkg string
+	if r.ReplacementPackage != "" {
+		targetPkg = r.ReplacementPackage
+	} else if len(replacements) > 0 {
+		targetPkg = owner
+	}
+
+	for idx := 0; idx < len(symbols); idx++ {
+		var replacement string
+		if len(replacements) > 0 {
+			replacement = replacements[idx]
+		}
+
+		t := base
+		t.id = r.expandedID(symbols[idx])
 
-		var targetPkg string
-		if r.ReplacementPackage != "" {
-			targetPkg = r.ReplacementPackage
-		} else if len(replacements) > 0 {
-			targetPkg = r.Package
+		if r.Regex != "" || isNamePattern(symbols[idx]) {
+			p, err := newNamePattern(owner, symbols[idx], r.Regex != "", targetPkg, replacement, t)
+			if err != nil {
+				return r.errorf("names", "contained an error in its name: %s", err)
+			}
+			*patterns = append(*patterns, p)
+			continue
 		}
 
-		for idx := 0; idx < len(symbols); idx++ {
-			var target string
-			if targetPkg != "" {
-				if len(replacements) > 0 {
-					target = targetPkg + "." + replacements[idx]
-				} else {
-					target = targetPkg + "." + symbols[idx]
-				}
+		if targetPkg != "" {
+			if replacement != "" {
+				t.replacement = targetPkg + "." + replacement
+			} else {
+				t.replacement = targetPkg + "." + symbols[idx]
 			}
-			expanded[r.Package+"."+symbols[idx]] = target
+		}
+		if err = addTarget(expanded, owner+"."+symbols[idx], t); err != nil {
+			return r.errorf("names", "%v", err)
 		}
 	}
-	return expanded, nil
+	return nil
 }
 
-func expandLine(line string) ([]string, error) {
-	var curr string
-	var specs []string
-	for _, sp := range strings.Split(line, ",") {
-		if sp == "" {
-			return nil, fmt.Errorf("target specification %q contained an empty element", line)
+func (r PackageRule) errorf(key string, format string, args ...interface{}) error {
+	loc := keyLocation(r.loc, r.keys, key)
+	return loc.errorf("package rule %q "+format, append([]interface{}{r.id()}, args...)...)
+}
+
+func (r PackageRule) id() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Path
+}
+
+// ids returns the IDs of the targets that the rule expands to. Unless the rule has an explicit ID each
+// target is identified by its own path so that the IDs do not contain the commas of brace expressions.
+func (r PackageRule) ids() []string {
+	if r.ID != "" {
+		return []string{r.ID}
+	}
+	packages, err := expandLine(r.Path)
+	if err != nil {
+		return []string{r.Path}
+	}
+	return packages
+}
+
+func (r SymbolRule) errorf(key string, format string, args ...interface{}) error {
+	loc := keyLocation(r.loc, r.keys, key)
+	return loc.errorf("symbol rule %q "+format, append([]interface{}{r.id()}, args...)...)
+}
+
+func (r SymbolRule) id() string {
+	if r.Regex != "" {
+		return r.expandedID(r.Regex)
+	}
+	return r.expandedID(r.Name)
+}
+
+// ids returns the IDs of the targets that the rule expands to as for PackageRule.
+func (r SymbolRule) ids() []string {
+	if r.ID != "" || r.Regex != "" {
+		return []string{r.id()}
+	}
+	symbols, err := expandLine(r.Name)
+	if err != nil {
+		return []string{r.id()}
+	}
+	ids := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		ids = append(ids, r.expandedID(symbol))
+	}
+	return ids
+}
+
+// expandedID returns the ID of the target that the rule expands to for the given name.
+func (r SymbolRule) expandedID(symbol string) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.owner() + "." + symbol
+}
+
+// owner returns the package, or the receiver type, whose symbols the rule applies to.
+func (r SymbolRule) owner() string {
+	if r.Receiver != "" {
+		return fmt.Sprintf("(%s.%s)", r.Package, strings.TrimPrefix(r.Receiver, "*"))
+	}
+	return r.Package
+}
+
+// validateIDs checks that rule IDs are unique and that explicit IDs can be listed in directives and
+// flags. Rules of a nested configuration may reuse the IDs of some of the targets of a parent rule as
+// they take precedence over these.
+func (c *Configuration) validateIDs() error {
+	type ruleID struct {
+		id        string
+		inherited int
+	}
+	var ids []ruleID
+	var explicit []string
+	var locs []location
+	for _, r := range c.Packages.Rules {
+		for _, id := range r.ids() {
+			ids, explicit = append(ids, ruleID{id, r.inherited}), append(explicit, r.ID)
+			locs = append(locs, keyLocation(r.loc, r.keys, "id"))
 		}
+	}
+	for _, r := range c.Symbols.Rules {
+		for _, id := range r.ids() {
+			ids, explicit = append(ids, ruleID{id, r.inherited}), append(explicit, r.ID)
+			locs = append(locs, keyLocation(r.loc, r.keys, "id"))
+		}
+	}
 
-		if (curr != "" || strings.Count(sp, "{") > 0) && strings.Count(sp, "}") == 0 {
-			curr += sp + ","
-			continue
+	var errs ConfigErrors
+	seen := map[ruleID]location{}
+	for idx, id := range ids {
+		prev, duplicate := seen[id]
+		switch {
+		case explicit[idx] != "" && strings.ContainsAny(id.id, ", \t"):
+			errs.add(locs[idx].errorf("rule ID %q may not contain commas or whitespace", id.id))
+		case strings.Contains(id.id, ","):
+			// Only the regular expressions of symbol rules are not expanded into IDs without commas.
+			errs.add(locs[idx].errorf("rule ID %q may not contain commas, the rule requires an explicit ID", id.id))
+		case duplicate && prev.file != "":
+			errs.add(locs[idx].errorf("rule ID %q is already used by the rule at %s", id.id, prev))
+		case duplicate:
+			errs.add(locs[idx].errorf("rule ID %q is used by more than one rule", id.id))
+		default:
+			seen[id] = locs[idx]
 		}
-		curr += sp
+	}
+	return errs.err()
+}
+
+// ruleSelection restricts the rules that are in effect to those whose IDs match one of the enabled
+// globs, if any, and none of the disabled ones.
+type ruleSelection struct {
+	enabled  []*regexp.Regexp
+	disabled []*regexp.Regexp
+}
 
-		if strings.Count(curr, "{") > 0 {
-			expanded, err := expandSpec(curr)
+func parseRuleSelection(enabled string, disabled string) (ruleSelection, error) {
+	var s ruleSelection
+	for _, spec := range []struct {
+		globs string
+		dest  *[]*regexp.Regexp
+	}{
+		{enabled, &s.enabled},
+		{disabled, &s.disabled},
+	} {
+		for _, glob := range strings.Split(spec.globs, ",") {
+			if glob = strings.TrimSpace(glob); glob == "" {
+				continue
+			}
+			re, err := globRegexp(glob)
 			if err != nil {
-				return nil, fmt.Errorf("target specification %q contained an error: %s", line, err)
+				return ruleSelection{}, fmt.Errorf("rule selection contains an error: %v", err)
 			}
-			specs = append(specs, expanded...)
-		} else {
-			specs = append(specs, curr)
+			*spec.dest = append(*spec.dest, re)
 		}
-		curr = ""
-	}
-	if curr != "" {
-		return nil, fmt.Errorf("target specification %q contains an unclosed brace", line)
 	}
-	return specs, nil
+	return s, nil
 }
 
-var expansionRE = regexp.MustCompile(`^([^{},]*)(?:{([^{}]+)})?([^{},]*)$`)
+func (s ruleSelection) includes(id string) bool {
+	matches := func(globs []*regexp.Regexp) bool {
+		for _, re := range globs {
+			if re.MatchString(id) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(s.enabled) == 0 || matches(s.enabled)) && !matches(s.disabled)
+}
 
-func expandSpec(spec string) ([]string, error) {
-	m := expansionRE.FindStringSubmatch(spec)
-	if len(m) == 0 {
-		return nil, fmt.Errorf("%q needs to be of form 'foo', 'foo/bar', 'foo.{bar,boo}', etc", spec)
+// addTarget adds the target of a package or symbol to the expanded rules. Rules that are inherited fewer
+// times take precedence while two rules of the same configuration may not list the same package or
+// symbol.
+func addTarget(expanded map[string]target, key string, t target) error {
+	prev, ok := expanded[key]
+	switch {
+	case !ok || t.inherited < prev.inherited:
+		expanded[key] = t
+	case t.inherited == prev.inherited:
+		return fmt.Errorf("lists %s which is also listed by %s", key, prev.describe())
 	}
+	return nil
+}
 
-	var specs []string
-	for _, s := range strings.Split(m[2], ",") {
-		expanded := m[1] + s + m[3]
-		if expanded == "" {
-			return nil, fmt.Errorf("%q contains an empty element", expanded)
-		}
-		specs = append(specs, expanded)
+// symbolPackage returns the package path of an expanded symbol, which is either of the form
+// 'pkg/path.Name' or '(pkg/path.Type).Name' for methods and fields.
+func symbolPackage(symbol string) string {
+	if strings.HasPrefix(symbol, "(") {
+		symbol = symbol[1:strings.Index(symbol, ")")]
 	}
-	return specs, nil
+	return symbol[:strings.LastIndex(symbol, ".")]
 }
@@ -1,7 +1,11 @@
 package anathema
 
 import (
+	"errors"
+	"path/filepath"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,8 +25,8 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{"go/ast": ""},
-				symbols:  map[string]string{},
+				packages: map[string]target{"go/ast": {id: "go/ast"}},
+				symbols:  map[string]target{},
 			},
 		},
 		"PackageRefactored": {
@@ -32,16 +36,16 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{
-					"fmt":       "",
-					"go/ast":    "",
-					"go/parser": "",
-					"go/token":  "",
-					"io":        "",
-					"io/ioutil": "",
-					"regexp":    "",
+				packages: map[string]target{
+					"fmt":       {id: "fmt"},
+					"go/ast":    {id: "go/ast"},
+					"go/parser": {id: "go/parser"},
+					"go/token":  {id: "go/token"},
+					"io":        {id: "io"},
+					"io/ioutil": {id: "io/ioutil"},
+					"regexp":    {id: "regexp"},
 				},
-				symbols: map[string]string{},
+				symbols: map[string]target{},
 			},
 		},
 		"PackageReplacements": {
@@ -54,12 +58,42 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{
-					"go/ast":    "alternative/ast",
-					"go/parser": "alternative/parser",
-					"go/token":  "alternative/token",
+				packages: map[string]target{
+					"go/ast":    {replacement: "alternative/ast", id: "go/ast"},
+					"go/parser": {replacement: "alternative/parser", id: "go/parser"},
+					"go/token":  {replacement: "alternative/token", id: "go/token"},
 				},
-				symbols: map[string]string{},
+				symbols: map[string]target{},
+			},
+		},
+		"PackageNestedReplacements": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{
+						Path:        "k8s.io/api/{apps,batch}/v1beta{1..2}",
+						Replacement: "k8s.io/api/{apps,batch}/{v1,v1}",
+					}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"k8s.io/api/apps/v1beta1":  {replacement: "k8s.io/api/apps/v1", id: "k8s.io/api/apps/v1beta1"},
+					"k8s.io/api/apps/v1beta2":  {replacement: "k8s.io/api/apps/v1", id: "k8s.io/api/apps/v1beta2"},
+					"k8s.io/api/batch/v1beta1": {replacement: "k8s.io/api/batch/v1", id: "k8s.io/api/batch/v1beta1"},
+					"k8s.io/api/batch/v1beta2": {replacement: "k8s.io/api/batch/v1", id: "k8s.io/api/batch/v1beta2"},
+				},
+				symbols: map[string]target{},
+			},
+		},
+		"PackageRewriteSelectors": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "go/ast", Replacement: "alternative/ast", Rewrite: "selectors"}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{"go/ast": {replacement: "alternative/ast", rewriteSelectors: true, id: "go/ast"}},
+				symbols:  map[string]target{},
 			},
 		},
 		"SymbolStandard": {
@@ -72,9 +106,9 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{},
-				symbols: map[string]string{
-					"fmt.Print": "",
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"fmt.Print": {id: "fmt.Print"},
 				},
 			},
 		},
@@ -89,9 +123,9 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{},
-				symbols: map[string]string{
-					"fmt.Print": "alternative.Print",
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"fmt.Print": {replacement: "alternative.Print", id: "fmt.Print"},
 				},
 			},
 		},
@@ -106,9 +140,9 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{},
-				symbols: map[string]string{
-					"fmt.Print": "fmt.Println",
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"fmt.Print": {replacement: "fmt.Println", id: "fmt.Print"},
 				},
 			},
 		},
@@ -124,9 +158,9 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{},
-				symbols: map[string]string{
-					"fmt.Print": "myfmt.Println",
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"fmt.Print": {replacement: "myfmt.Println", id: "fmt.Print"},
 				},
 			},
 		},
@@ -142,11 +176,30 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 			expected: &configuration{
-				packages: map[string]string{},
-				symbols: map[string]string{
-					"fmt.Print":   "myfmt.Fprint",
-					"fmt.Printf":  "myfmt.Fprintf",
-					"fmt.Println": "myfmt.Fprintln",
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"fmt.Print":   {replacement: "myfmt.Fprint", id: "fmt.Print"},
+					"fmt.Printf":  {replacement: "myfmt.Fprintf", id: "fmt.Printf"},
+					"fmt.Println": {replacement: "myfmt.Fprintln", id: "fmt.Println"},
+				},
+			},
+		},
+		"SymbolReceiver": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:         "database/sql",
+						Receiver:        "*DB",
+						Name:            "Query,Exec",
+						ReplacementName: "QueryContext,ExecContext",
+					}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"(database/sql.DB).Query": {replacement: "(database/sql.DB).QueryContext", id: "(database/sql.DB).Query"},
+					"(database/sql.DB).Exec":  {replacement: "(database/sql.DB).ExecContext", id: "(database/sql.DB).Exec"},
 				},
 			},
 		},
@@ -182,6 +235,20 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 		},
+		"PackageUnknownRewrite": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "foo", Replacement: "bar", Rewrite: "rename"}},
+				},
+			},
+		},
+		"PackageRewriteWithoutReplacement": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "foo", Rewrite: "alias"}},
+				},
+			},
+		},
 		"SymbolMissingPackage": {
 			config: Configuration{
 				Symbols: Symbols{
@@ -228,6 +295,385 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 		},
+		"SymbolTemplateWithoutCall": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "io/ioutil", Name: "ReadAll", Template: "io.ReadAll($r)"}},
+				},
+			},
+		},
+		"SymbolTemplateMultipleNames": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "ReadAll,ReadFile",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadAll($r)",
+						Template:           "io.ReadAll($r)",
+					}},
+				},
+			},
+		},
+		"SymbolTemplateMismatchedCall": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "ReadAll",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadFile($r)",
+						Template:           "io.ReadAll($r)",
+					}},
+				},
+			},
+		},
+		"SymbolTemplateUnboundPlaceholder": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "ReadAll",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadAll($r)",
+						Template:           "io.ReadAll($reader)",
+					}},
+				},
+			},
+		},
+		"SymbolTemplateUnknownPackage": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "ReadAll",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadAll($r)",
+						Template:           "io.ReadAll(bufio.NewReader($r))",
+					}},
+				},
+			},
+		},
+		"SymbolTemplateInvalidExpression": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "ReadAll",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadAll($r)",
+						Template:           "io.ReadAll($r",
+					}},
+				},
+			},
+		},
+		"SymbolInvalidReceiver": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "database/sql", Receiver: "DB,Tx", Name: "Query"}},
+				},
+			},
+		},
+		"SymbolReceiverReplacementPackage": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "database/sql", Receiver: "DB", Name: "Query", ReplacementPackage: "mysql"}},
+				},
+			},
+		},
+		"PackagePatterns": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{
+						{Path: "github.com/aws/aws-sdk-go/...", Replacement: "github.com/aws/aws-sdk-go-v2/..."},
+						{Path: "k8s.io/api/*/v1{,beta1}"},
+					},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"github.com/aws/aws-sdk-go/...": {replacement: "github.com/aws/aws-sdk-go-v2/...", id: "github.com/aws/aws-sdk-go/..."},
+					"k8s.io/api/*/v1":               {id: "k8s.io/api/*/v1"},
+					"k8s.io/api/*/v1beta1":          {id: "k8s.io/api/*/v1beta1"},
+				},
+				packagePatterns: []string{"k8s.io/api/*/v1beta1", "k8s.io/api/*/v1", "github.com/aws/aws-sdk-go/..."},
+				symbols:         map[string]target{},
+			},
+		},
+		"PackageInvalidSubtree": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "github.com/aws/.../s3"}},
+				},
+			},
+		},
+		"PackageInvalidGlob": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "github.com/aws/[a-"}},
+				},
+			},
+		},
+		"PackageGlobWithSubtreeReplacement": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "k8s.io/api/*/...", Replacement: "k8s.io/api-v2/..."}},
+				},
+			},
+		},
+		"SymbolPatterns": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{
+						{Package: "github.com/aws/aws-sdk-go/...", Name: "New"},
+						{Package: "github.com/aws/aws-sdk-go/service/*", Name: "New", ReplacementName: "NewFromConfig"},
+					},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"github.com/aws/aws-sdk-go/....New": {id: "github.com/aws/aws-sdk-go/....New"},
+					"github.com/aws/aws-sdk-go/service/*.New": {
+						replacement: "github.com/aws/aws-sdk-go/service/*.NewFromConfig",
+						id:          "github.com/aws/aws-sdk-go/service/*.New",
+					},
+				},
+				symbolPatterns: []string{"github.com/aws/aws-sdk-go/service/*", "github.com/aws/aws-sdk-go/..."},
+			},
+		},
+		"SymbolNamePatterns": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{
+						{Package: "regexp", Name: "Must*", ReplacementName: "$1"},
+						{Package: "log", Regex: "^Fatal(?P<suffix>f|ln)?$", ReplacementPackage: "logging", ReplacementName: "Error${suffix}"},
+					},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols:  map[string]target{},
+				symbolNames: []namePattern{
+					{
+						owner:           "regexp",
+						raw:             "Must*",
+						re:              regexp.MustCompile("^Must(.*)$"),
+						replacementPkg:  "regexp",
+						replacementName: "${1}",
+						base:            target{id: "regexp.Must*"},
+					},
+					{
+						owner:           "log",
+						raw:             "^Fatal(?P<suffix>f|ln)?$",
+						re:              regexp.MustCompile("^Fatal(?P<suffix>f|ln)?$"),
+						replacementPkg:  "logging",
+						replacementName: "Error${suffix}",
+						base:            target{id: "log.^Fatal(?P<suffix>f|ln)?$"},
+					},
+				},
+			},
+		},
+		"SymbolNamesAndRegex": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "log", Name: "Fatal", Regex: "^Fatal.*"}},
+				},
+			},
+		},
+		"SymbolInvalidRegex": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "log", Regex: "^Fatal(f"}},
+				},
+			},
+		},
+		"SymbolInvalidGlob": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "log", Name: "Fatal[f"}},
+				},
+			},
+		},
+		"SymbolUnknownCaptureGroup": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "regexp", Name: "Must*", ReplacementName: "$2"}},
+				},
+			},
+		},
+		"SymbolTemplateWithNamePattern": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package:            "io/ioutil",
+						Name:               "Read*",
+						ReplacementPackage: "io",
+						Call:               "ioutil.ReadAll($r)",
+						Template:           "io.ReadAll($r)",
+					}},
+				},
+			},
+		},
+		"PackageImporterScopes": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "database/sql", AllowedIn: "pkg/platform/{db,migrations}", DeniedIn: "pkg/..."}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"database/sql": {
+						scope: scope{only: []string{"pkg/..."}, except: []string{"pkg/platform/db", "pkg/platform/migrations"}},
+						id:    "database/sql",
+					},
+				},
+				symbols: map[string]target{},
+			},
+		},
+		"PackageWhitelistImporterScopes": {
+			config: Configuration{
+				Packages: Packages{
+					Whitelist: true,
+					Rules:     []PackageRule{{Path: "database/sql", AllowedIn: "pkg/platform/db"}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"database/sql": {allow: true, scope: scope{only: []string{"pkg/platform/db"}}, id: "database/sql"},
+					"...":          {id: "packages-whitelist"},
+				},
+				packagePatterns: []string{"..."},
+				symbols:         map[string]target{},
+			},
+		},
+		"PackageWhitelistAllowStd": {
+			config: Configuration{
+				Packages: Packages{
+					Whitelist: true,
+					Rules:     []PackageRule{{Path: "github.com/pkg/errors"}},
+				},
+				AllowStd: true,
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"github.com/pkg/errors": {allow: true, id: "github.com/pkg/errors"},
+					"@std":                  {allow: true, id: "@std"},
+					"...":                   {id: "packages-whitelist"},
+				},
+				packagePatterns: []string{"@std", "..."},
+				symbols:         map[string]target{},
+			},
+		},
+		"RuleEffects": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{
+						{Path: "pkg/internal/old", Effect: EffectDeny},
+						{Path: "pkg/internal/new", Effect: EffectAllow, AllowedIn: "pkg/app"},
+					},
+				},
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "pkg/internal/old", Name: "Background", Effect: EffectAllow}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"pkg/internal/old": {id: "pkg/internal/old"},
+					"pkg/internal/new": {allow: true, scope: scope{only: []string{"pkg/app"}}, id: "pkg/internal/new"},
+				},
+				symbols: map[string]target{
+					"pkg/internal/old.Background": {allow: true, id: "pkg/internal/old.Background"},
+				},
+			},
+		},
+		"SymbolScopedWhitelist": {
+			config: Configuration{
+				Symbols: Symbols{
+					Whitelist: true,
+					Scoped:    true,
+					Rules:     []SymbolRule{{Package: "pkg/internal/new", Name: "Background", AllowedIn: "pkg/app"}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"pkg/internal/new.Background": {allow: true, scope: scope{only: []string{"pkg/app"}}, id: "pkg/internal/new.Background"},
+				},
+				symbolNames: []namePattern{{
+					owner: "pkg/internal/new",
+					raw:   "*",
+					re:    regexp.MustCompile("^(.*)$"),
+					base:  target{scope: scope{only: []string{"pkg/app"}}, id: "pkg/internal/new.*"},
+				}},
+			},
+		},
+		"RuleUnknownEffect": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "pkg/internal/old", Effect: "forbid"}},
+				},
+			},
+		},
+		"PackageAllowReplacement": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "pkg/internal/old", Replacement: "pkg/internal/new", Effect: EffectAllow}},
+				},
+			},
+		},
+		"SymbolAllowMessage": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", Effect: EffectAllow, Message: "$symbol is fine"}},
+				},
+			},
+		},
+		"InheritedRulesShadowed": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{
+						{ID: "nested", Path: "io/ioutil", Effect: EffectAllow},
+						{ID: "parent", Path: "io/{ioutil,fs}", inherited: 1},
+					},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"io/ioutil": {allow: true, id: "nested"},
+					"io/fs":     {id: "parent", inherited: 1},
+				},
+				symbols: map[string]target{},
+			},
+		},
+		"PackageInvalidImporterScope": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "database/sql", AllowedIn: "pkg/.../db"}},
+				},
+			},
+		},
+		"SymbolImporterScopes": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", AllowedIn: "cmd/..."}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"os.Exit": {scope: scope{except: []string{"cmd/..."}}, id: "os.Exit"},
+				},
+			},
+		},
+		"SymbolWhitelistReceiver": {
+			config: Configuration{
+				Symbols: Symbols{
+					Whitelist: true,
+					Rules:     []SymbolRule{{Package: "database/sql", Receiver: "DB", Name: "Query"}},
+				},
+			},
+		},
 		"SymbolWhitelistReplacementPackage": {
 			config: Configuration{
 				Symbols: Symbols{
@@ -244,6 +690,223 @@ func TestConfiguration(t *testing.T) {
 				},
 			},
 		},
+		"RuleSeverities": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", Severity: SeverityWarning}},
+				},
+				Symbols: Symbols{
+					Rules: []SymbolRule{
+						{Package: "os", Name: "Exit", Severity: SeverityInfo},
+						{Package: "log", Name: "Fatal*", Severity: SeverityWarning},
+					},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{"io/ioutil": {severity: SeverityWarning, id: "io/ioutil"}},
+				symbols:  map[string]target{"os.Exit": {severity: SeverityInfo, id: "os.Exit"}},
+				symbolNames: []namePattern{{
+					owner: "log",
+					raw:   "Fatal*",
+					re:    regexp.MustCompile("^Fatal(.*)$"),
+					base:  target{severity: SeverityWarning, id: "log.Fatal*"},
+				}},
+			},
+		},
+		"PackageUnknownSeverity": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", Severity: "fatal"}},
+				},
+			},
+		},
+		"SymbolUnknownSeverity": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", Severity: "critical"}},
+				},
+			},
+		},
+		"SymbolWhitelistSeverity": {
+			config: Configuration{
+				Symbols: Symbols{
+					Whitelist: true,
+					Rules:     []SymbolRule{{Package: "os", Name: "Exit", Severity: SeverityWarning}},
+				},
+			},
+		},
+		"RuleDeadlines": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", DisabledUntil: "2026-01-01", ErrorFrom: "2027-01-01"}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{
+					"io/ioutil": {id: "io/ioutil", deadlines: deadlines{
+						disabledUntil: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+						errorFrom:     time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+					}},
+				},
+				symbols: map[string]target{},
+			},
+		},
+		"PackageInvalidDeadline": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", ErrorFrom: "01/01/2027"}},
+				},
+			},
+		},
+		"PackageUnorderedDeadlines": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", WarnFrom: "2027-01-01", ErrorFrom: "2026-01-01"}},
+				},
+			},
+		},
+		"SymbolSeverityAndDeadlines": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", Severity: SeverityWarning, ErrorFrom: "2027-01-01"}},
+				},
+			},
+		},
+		"SymbolWhitelistDeadlines": {
+			config: Configuration{
+				Symbols: Symbols{
+					Whitelist: true,
+					Rules:     []SymbolRule{{Package: "os", Name: "Exit", DisabledUntil: "2027-01-01"}},
+				},
+			},
+		},
+		"RuleMessages": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{
+						Package: "os",
+						Name:    "Exit",
+						Message: "$importer should return errors instead of calling $symbol",
+						URL:     "https://example.com/errors",
+					}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{},
+				symbols: map[string]target{
+					"os.Exit": {
+						message: "$importer should return errors instead of calling $symbol",
+						url:     "https://example.com/errors",
+						id:      "os.Exit",
+					},
+				},
+			},
+		},
+		"SymbolUnknownMessagePlaceholder": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", Message: "$package should not exit"}},
+				},
+			},
+		},
+		"PackageWhitelistMessage": {
+			config: Configuration{
+				Packages: Packages{
+					Whitelist: true,
+					Rules:     []PackageRule{{Path: "os", URL: "https://example.com/os"}},
+				},
+			},
+		},
+		"RuleIDs": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{ID: "no-ioutil", Path: "io/ioutil"}},
+				},
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit"}},
+				},
+			},
+			expected: &configuration{
+				packages: map[string]target{"io/ioutil": {id: "no-ioutil"}},
+				symbols:  map[string]target{"os.Exit": {id: "os.Exit"}},
+			},
+		},
+		"RuleDuplicateIDs": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{ID: "legacy", Path: "io/ioutil"}},
+				},
+				Symbols: Symbols{
+					Rules: []SymbolRule{{ID: "legacy", Package: "os", Name: "Exit"}},
+				},
+			},
+		},
+		"RuleInvalidID": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{ID: "no exit", Package: "os", Name: "Exit"}},
+				},
+			},
+		},
+		"RuleRegexIDWithComma": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "log", Regex: "^Fatal.{0,2}$"}},
+				},
+			},
+		},
+		"PackageListedTwice": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil"}, {Path: "io{,/ioutil}"}},
+				},
+			},
+		},
+		"SymbolListedTwice": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{
+						{Package: "os", Name: "Exit"},
+						{Package: "os", Name: "Exit,Getenv", ReplacementPackage: "pkg/os"},
+					},
+				},
+			},
+		},
+		"PackageInvalidImportPath": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "github.com//foo"}},
+				},
+			},
+		},
+		"PackageInvalidReplacementPath": {
+			config: Configuration{
+				Packages: Packages{
+					Rules: []PackageRule{{Path: "io/ioutil", Replacement: "io/.ioutil"}},
+				},
+			},
+		},
+		"SymbolInvalidImportPath": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os exec", Name: "Command"}},
+				},
+			},
+		},
+		"SymbolInvalidIdentifier": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit()"}},
+				},
+			},
+		},
+		"SymbolInvalidReplacementName": {
+			config: Configuration{
+				Symbols: Symbols{
+					Rules: []SymbolRule{{Package: "os", Name: "Exit", ReplacementName: "exit-now"}},
+				},
+			},
+		},
 	}
 
 	for name := range testcases {
@@ -262,39 +925,166 @@ func TestConfiguration(t *testing.T) {
 	}
 }
 
+func TestTemplateQualifier(t *testing.T) {
+	t.Parallel()
+
+	for pkg, tmpl := range map[string]string{
+		"gopkg.in/yaml.v3":             "yaml.Marshal($v)",
+		"github.com/mattn/go-sqlite3":  "sqlite3.Open($v)",
+		"pkg/internal/encoding/legacy": "encoding.Marshal($v)",
+	} {
+		config := Configuration{
+			Symbols: Symbols{
+				Rules: []SymbolRule{{
+					Package:            "encoding/json",
+					Name:               "Marshal",
+					ReplacementPackage: pkg,
+					Call:               "json.Marshal($v)",
+					Template:           tmpl,
+				}},
+			},
+		}
+		_, err := config.validate()
+		assert.NoError(t, err, tmpl)
+	}
+}
+
+func TestScope(t *testing.T) {
+	t.Parallel()
+
+	s := scope{only: []string{"pkg/..."}, except: []string{"pkg/platform/*"}}
+	assert.True(t, s.includes("pkg", matchPattern))
+	assert.True(t, s.includes("pkg/service", matchPattern))
+	assert.False(t, s.includes("pkg/platform/db", matchPattern))
+	assert.False(t, s.includes("cmd/tool", matchPattern))
+	assert.True(t, scope{}.includes("cmd/tool", matchPattern))
+}
+
+func TestExpandLine(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		line     string
+		expected []string
+	}{
+		"Plain":     {line: "foo,bar/baz", expected: []string{"foo", "bar/baz"}},
+		"Group":     {line: "go/{ast,parser},io", expected: []string{"go/ast", "go/parser", "io"}},
+		"Optional":  {line: "v1{,beta1}", expected: []string{"v1", "v1beta1"}},
+		"Nested":    {line: "api/{compute/{v1,beta},storage/v1}", expected: []string{"api/compute/v1", "api/compute/beta", "api/storage/v1"}},
+		"Product":   {line: "k8s.io/api/{apps,batch}/{v1,v1beta1}", expected: []string{"k8s.io/api/apps/v1", "k8s.io/api/apps/v1beta1", "k8s.io/api/batch/v1", "k8s.io/api/batch/v1beta1"}},
+		"Range":     {line: "v{1..3}", expected: []string{"v1", "v2", "v3"}},
+		"Reverse":   {line: "v1.{2..0}", expected: []string{"v1.2", "v1.1", "v1.0"}},
+		"Step":      {line: "{0..10..5}", expected: []string{"0", "5", "10"}},
+		"Padded":    {line: "r{08..10}", expected: []string{"r08", "r09", "r10"}},
+		"Escaped":   {line: `a\,b,c\{d\}`, expected: []string{"a,b", "c{d}"}},
+		"Reference": {line: "Error${suffix},{Warn,Info}$1", expected: []string{"Error${suffix}", "Warn$1", "Info$1"}},
+	}
+
+	for name := range testcases {
+		testcase := testcases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expanded, err := expandLine(testcase.line)
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, expanded)
+		})
+	}
+
+	for _, line := range []string{"", "a,,b", "{a,b", "a}", "{,}", `a\`, "{1..3..0}", "{0..5000}"} {
+		_, err := expandLine(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestSelectorMatching(t *testing.T) {
+	t.Parallel()
+
+	c := &configuration{mainModule: "github.com/foo/bar"}
+	assert.True(t, c.match("@std", "net/http"))
+	assert.False(t, c.match("@std", "github.com/foo/bar"))
+	assert.True(t, c.match("@main-module", "github.com/foo/bar/pkg/db"))
+	assert.False(t, c.match("@main-module", "github.com/foo/barbaz"))
+	assert.True(t, c.match("@thirdparty", "github.com/foo/barbaz"))
+	assert.False(t, c.match("@thirdparty", "net/http"))
+	assert.False(t, c.match("@thirdparty", "C"))
+	assert.True(t, c.match("@module:github.com/foo/barbaz", "github.com/foo/barbaz/sub"))
+	assert.True(t, c.match("@internal", "github.com/foo/bar/internal/db"))
+	assert.True(t, c.match("@internal", "internal/poll"))
+	assert.False(t, c.match("@internal", "github.com/foo/internals"))
+	assert.True(t, c.match("@cgo", "C"))
+
+	// The main module is unknown outside of a run.
+	assert.False(t, (&configuration{}).match("@thirdparty", "github.com/foo/bar"))
+
+	for _, pattern := range []string{"@std", "@module:github.com/foo/bar"} {
+		assert.NoError(t, validatePattern(pattern), pattern)
+	}
+	for _, pattern := range []string{"@stdlib", "@module:github.com/foo/...", "foo/@std", "@std/..."} {
+		assert.Error(t, validatePattern(pattern), pattern)
+	}
+
+	assert.Equal(t, []string{"github.com/foo/...", "@module:github.com/foo", "@internal", "@std", "@thirdparty"}, sortedPatterns([]string{
+		"@thirdparty",
+		"@std",
+		"@internal",
+		"github.com/foo/bar",
+		"@module:github.com/foo",
+		"github.com/foo/...",
+	}))
+}
+
+func TestPrecedence(t *testing.T) {
+	t.Parallel()
+
+	config := Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{
+				{Path: "github.com/aws/aws-sdk-go/..."},
+				{Path: "github.com/aws/aws-sdk-go/service/*"},
+				{Path: "github.com/aws/aws-sdk-go/service/s3"},
+				{Path: "k8s.io/api/*/v1"},
+			},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{Package: "github.com/aws/aws-sdk-go/...", Name: "New"},
+				{Package: "github.com/aws/aws-sdk-go/aws", Name: "New,String"},
+				{Package: "github.com/aws/aws-sdk-go/aws", Name: "New*"},
+				{Package: "github.com/aws/aws-sdk-go/aws", Regex: "^(New|Must).*"},
+			},
+		},
+	}
+
+	precedence, err := config.Precedence()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"package rule github.com/aws/aws-sdk-go/service/* takes precedence over github.com/aws/aws-sdk-go/...",
+		"package rule github.com/aws/aws-sdk-go/service/s3 takes precedence over github.com/aws/aws-sdk-go/...",
+		"package rule github.com/aws/aws-sdk-go/service/s3 takes precedence over github.com/aws/aws-sdk-go/service/*",
+		"symbol rule github.com/aws/aws-sdk-go/aws.New takes precedence over github.com/aws/aws-sdk-go/....New",
+		"symbol rule github.com/aws/aws-sdk-go/aws.New takes precedence over github.com/aws/aws-sdk-go/aws.New*",
+		"symbol rule github.com/aws/aws-sdk-go/aws.New takes precedence over github.com/aws/aws-sdk-go/aws.^(New|Must).*",
+		"symbol rule github.com/aws/aws-sdk-go/aws.New* takes precedence over github.com/aws/aws-sdk-go/aws.^(New|Must).*",
+	}, precedence)
+}
+
 func TestInconsistencyCheck(t *testing.T) {
 	t.Parallel()
 
 	testcases := map[string]configuration{
-		"SymbolReplaceWithBlacklistedPackage": {
-			packages:          map[string]string{"foo/bar": ""},
-			whitelistPackages: false,
-			symbols:           map[string]string{"pkg.Foo": "foo/bar.Func"},
-			whitelistSymbols:  false,
-		},
-		"SymbolReplaceWithNonWhitelistedPackage": {
-			packages:          map[string]string{},
-			whitelistPackages: true,
-			symbols:           map[string]string{"pkg.Foo": "foo/bar.Func"},
-			whitelistSymbols:  false,
-		},
-		"WhitelistedSymbolInBlacklistedPackage": {
-			packages:          map[string]string{"pkg": ""},
-			whitelistPackages: false,
-			symbols:           map[string]string{"pkg.Foo": ""},
-			whitelistSymbols:  true,
-		},
-		"WhitelistedSymbolInNonWhitelistedPackage": {
-			packages:          map[string]string{},
-			whitelistPackages: true,
-			symbols:           map[string]string{"pkg.Foo": ""},
-			whitelistSymbols:  true,
+		"SymbolReplaceWithDeniedPackage": {
+			packages: map[string]target{"foo/bar": {}},
+			symbols:  map[string]target{"pkg.Foo": {replacement: "foo/bar.Func"}},
+		},
+		"SymbolReplaceWithUnlistedPackage": {
+			packages:        map[string]target{"...": {}},
+			packagePatterns: []string{"..."},
+			symbols:         map[string]target{"pkg.Foo": {replacement: "foo/bar.Func"}},
 		},
 		"ConflictingSymbolAndPackageReplace": {
-			packages:          map[string]string{"pkg": "foo/bar"},
-			whitelistPackages: false,
-			symbols:           map[string]string{"pkg.Foo": "bar/foo.Func"},
-			whitelistSymbols:  false,
+			packages: map[string]target{"pkg": {replacement: "foo/bar"}},
+			symbols:  map[string]target{"pkg.Foo": {replacement: "bar/foo.Func"}},
 		},
 	}
 
@@ -305,4 +1095,262 @@ func TestInconsistencyCheck(t *testing.T) {
 			assert.Error(t, err)
 		})
 	}
+
+	// Replacements into the main module can only be checked once it is known.
+	for replacement, consistent := range map[string]bool{"github.com/foo/bar/util": true, "github.com/foo/baz": false} {
+		config := Configuration{
+			Packages:        Packages{Whitelist: true},
+			Symbols:         Symbols{Rules: []SymbolRule{{Package: "pkg", Name: "Foo", ReplacementPackage: replacement}}},
+			AllowMainModule: true,
+		}
+		c, err := config.validate()
+		require.NoError(t, err)
+		c.mainModule = "github.com/foo/bar"
+		assert.Equal(t, consistent, checkInconsistencies(c) == nil, replacement)
+	}
+}
+
+func TestParseSeverities(t *testing.T) {
+	t.Parallel()
+
+	severities, err := parseSeverities(" warning=error, os.Exit=info,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"warning": SeverityError, "os.Exit": SeverityInfo}, severities)
+
+	_, err = parseSeverities("warning")
+	assert.Error(t, err)
+	_, err = parseSeverities("os.Exit=fatal")
+	assert.Error(t, err)
+}
+
+func TestDeadlineSeverities(t *testing.T) {
+	t.Parallel()
+
+	d, err := parseDeadlines("2026-01-01", "2026-06-01", "2027-01-01")
+	require.NoError(t, err)
+
+	testcases := map[string]struct {
+		now       time.Time
+		active    bool
+		severity  string
+		forbidden bool
+	}{
+		"Disabled": {now: time.Date(2025, time.December, 31, 23, 59, 0, 0, time.UTC)},
+		"Info":     {now: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), active: true, severity: SeverityInfo, forbidden: true},
+		"Warning":  {now: time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), active: true, severity: SeverityWarning, forbidden: true},
+		"Error":    {now: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), active: true, severity: SeverityError},
+	}
+
+	for name := range testcases {
+		testcase := testcases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, ok := d.apply(target{deadlines: d}, testcase.now)
+			require.Equal(t, testcase.active, ok)
+			assert.Equal(t, testcase.severity, result.severity)
+			assert.Equal(t, testcase.forbidden, !result.forbiddenFrom.IsZero())
+		})
+	}
+}
+
+func TestRuleSelection(t *testing.T) {
+	t.Parallel()
+
+	s, err := parseRuleSelection("migrate-*,os.Exit", "migrate-logging")
+	require.NoError(t, err)
+	assert.True(t, s.includes("migrate-errors"))
+	assert.True(t, s.includes("os.Exit"))
+	assert.False(t, s.includes("migrate-logging"))
+	assert.False(t, s.includes("database/sql"))
+
+	s, err = parseRuleSelection("", "pkg/internal/*")
+	require.NoError(t, err)
+	assert.True(t, s.includes("os.Exit"))
+	assert.False(t, s.includes("pkg/internal/helpers.Variable"))
+
+	_, err = parseRuleSelection("migrate-[", "")
+	assert.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	parent := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{Path: "io/ioutil"}, {Path: "database/sql"}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{{ID: "no-exit", Package: "os", Name: "Exit"}},
+		},
+	}
+	nested := &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{Path: "database/sql", AllowedIn: "pkg/db"}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{{ID: "no-exit", Package: "os", Name: "Exit", Severity: SeverityWarning}},
+		},
+		IndirectTypes: true,
+	}
+
+	assert.Equal(t, &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{Path: "database/sql", AllowedIn: "pkg/db"}, {Path: "io/ioutil", inherited: 1}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{{ID: "no-exit", Package: "os", Name: "Exit", Severity: SeverityWarning}},
+		},
+		IndirectTypes: true,
+	}, parent.merge(nested))
+	assert.Equal(t, nested, (*Configuration)(nil).merge(nested))
+
+	// Nested rules may narrow some of the targets of a parent rule.
+	narrowed, err := (&Configuration{
+		Packages: Packages{Rules: []PackageRule{{Path: "io/{ioutil,fs}"}}},
+		Symbols:  Symbols{Rules: []SymbolRule{{Package: "os", Name: "Exit,Getenv"}}},
+	}).merge(&Configuration{
+		Packages: Packages{Rules: []PackageRule{{Path: "io/ioutil", Severity: SeverityWarning}}},
+		Symbols:  Symbols{Rules: []SymbolRule{{Package: "os", Name: "Exit", Severity: SeverityInfo}}},
+	}).validate()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]target{
+		"io/ioutil": {severity: SeverityWarning, id: "io/ioutil"},
+		"io/fs":     {id: "io/fs", inherited: 1},
+	}, narrowed.packages)
+	assert.Equal(t, map[string]target{
+		"os.Exit":   {severity: SeverityInfo, id: "os.Exit"},
+		"os.Getenv": {id: "os.Getenv", inherited: 1},
+	}, narrowed.symbols)
+
+	deadline := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clocked := parent.merge(&Configuration{Clock: func() time.Time { return deadline }})
+	assert.Equal(t, deadline, clocked.now())
+	assert.Equal(t, deadline, clocked.merge(&Configuration{}).now())
+}
+
+func TestLoadConfiguration(t *testing.T) {
+	t.Parallel()
+
+	at := func(file string, line int, column int) location {
+		return location{file: filepath.Join("testdata", "config", file), line: line, column: column}
+	}
+
+	c, err := LoadConfiguration(filepath.Join("testdata", "config", "team.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]location{
+		"path":        at("base.yml", 3, 13),
+		"replacement": at("base.yml", 4, 20),
+	}, c.Packages.Rules[0].keys)
+	for idx := range c.Packages.Rules {
+		c.Packages.Rules[idx].keys = nil
+	}
+	for idx := range c.Symbols.Rules {
+		c.Symbols.Rules[idx].keys = nil
+	}
+	assert.Equal(t, &Configuration{
+		Packages: Packages{
+			Rules: []PackageRule{{
+				Path:        "io/ioutil",
+				Replacement: "io",
+				loc:         at("base.yml", 3, 7),
+				inherited:   1,
+			}},
+		},
+		Symbols: Symbols{
+			Rules: []SymbolRule{
+				{
+					ID:       "no-exit",
+					Package:  "os",
+					Name:     "Exit",
+					Severity: SeverityWarning,
+					loc:      at("team.yml", 7, 7),
+				},
+				{
+					Package:            "log",
+					Name:               "Fatal",
+					ReplacementPackage: "pkg/logging",
+					loc:                at(filepath.Join("shared", "logging.yml"), 3, 7),
+				},
+			},
+		},
+	}, c)
+
+	_, err = LoadConfiguration(filepath.Join("testdata", "config", "cycle.yml"))
+	assert.Error(t, err)
+
+	c, err = LoadConfiguration(filepath.Join("testdata", "config", "conflict.yml"))
+	require.NoError(t, err)
+	_, err = c.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), filepath.Join("testdata", "config", "conflict.yml"))
+	assert.Contains(t, err.Error(), filepath.Join("testdata", "config", "shared", "logging.yml"))
+}
+
+func TestConfigurationErrors(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		file     string
+		line     int
+		contains string
+	}{
+		"Missing":    {file: "missing.yml", contains: "no such file"},
+		"Invalid":    {file: "invalid.yml", line: 6, contains: "cannot unmarshal"},
+		"Malformed":  {file: "malformed.yml", line: 3, contains: "cannot start any token"},
+		"Cycle":      {file: "cycle.yml", contains: "extends or includes itself"},
+		"UnknownKey": {file: "unknown.yml", line: 4, contains: `unknown key "name" in SymbolRule, did you mean "names"?`},
+	}
+
+	for name := range testcases {
+		testcase := testcases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadConfiguration(filepath.Join("testdata", "config", testcase.file))
+			var configErr *ConfigError
+			require.True(t, errors.As(err, &configErr))
+			assert.Equal(t, testcase.line, configErr.Line)
+			assert.Contains(t, configErr.Err.Error(), testcase.contains)
+		})
+	}
+}
+
+func TestConfigurationUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	_, err := LoadConfiguration(filepath.Join("testdata", "config", "unknowns.yml"))
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.Equal(t, 4, errs[0].Line)
+	assert.Contains(t, errs[0].Err.Error(), `unknown key "name" in SymbolRule`)
+	assert.Equal(t, 6, errs[1].Line)
+	assert.Contains(t, errs[1].Err.Error(), `unknown key "regexp" in SymbolRule`)
+}
+
+func TestConfigurationErrorLocations(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join("testdata", "config", "errors.yml")
+	c, err := LoadConfiguration(path)
+	require.NoError(t, err)
+
+	_, err = c.validate()
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs))
+
+	type position struct {
+		line, column int
+	}
+	var positions []position
+	for _, e := range errs {
+		assert.Equal(t, path, e.File)
+		positions = append(positions, position{e.Line, e.Column})
+	}
+	assert.Equal(t, []position{{5, 17}, {6, 13}, {8, 18}, {14, 11}, {19, 25}, {22, 25}}, positions)
+	assert.Contains(t, errs[0].Error(), `package rule "io/ioutil" has an unknown severity`)
+	assert.Contains(t, errs[2].Error(), `package rule "database/sql" contained an error in its importers`)
+	assert.Contains(t, errs[3].Error(), `rule ID "no-exit" is already used by the rule at `+path+":11:11")
+	assert.Contains(t, errs[5].Error(), `symbol rule "log.Print" contained an error in its replacement`)
 }
@@ -0,0 +1,75 @@
+package anathema
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rules may be phased in with deadlines so that a migration can be announced ahead of time. A rule is
+// ignored until its DisabledUntil date, after which its findings are reported as information until its
+// WarnFrom date, as warnings until its ErrorFrom date and as errors from then on. Findings that are
+// reported before the ErrorFrom date mention when they will be forbidden. Dates are of the form
+// '2006-01-02' and start at midnight UTC.
+
+const dateLayout = "2006-01-02"
+
+// deadlines holds the dates at which a rule takes effect. Unset dates are zero.
+type deadlines struct {
+	disabledUntil time.Time
+	warnFrom      time.Time
+	errorFrom     time.Time
+}
+
+func parseDeadlines(disabledUntil string, warnFrom string, errorFrom string) (deadlines, error) {
+	var d deadlines
+	for _, date := range []struct {
+		name  string
+		value string
+		dest  *time.Time
+	}{
+		{"disabled_until", disabledUntil, &d.disabledUntil},
+		{"warn_from", warnFrom, &d.warnFrom},
+		{"error_from", errorFrom, &d.errorFrom},
+	} {
+		if date.value == "" {
+			continue
+		}
+		t, err := time.Parse(dateLayout, date.value)
+		if err != nil {
+			return deadlines{}, fmt.Errorf("%s %q is not a date of the form 'YYYY-MM-DD'", date.name, date.value)
+		}
+		*date.dest = t
+	}
+
+	switch {
+	case !d.warnFrom.IsZero() && d.warnFrom.Before(d.disabledUntil):
+		return deadlines{}, fmt.Errorf("warn_from %s precedes disabled_until %s", warnFrom, disabledUntil)
+	case !d.errorFrom.IsZero() && d.errorFrom.Before(d.disabledUntil):
+		return deadlines{}, fmt.Errorf("error_from %s precedes disabled_until %s", errorFrom, disabledUntil)
+	case !d.errorFrom.IsZero() && d.errorFrom.Before(d.warnFrom):
+		return deadlines{}, fmt.Errorf("error_from %s precedes warn_from %s", errorFrom, warnFrom)
+	}
+	return d, nil
+}
+
+// apply updates the severity of the target according to the deadlines at the given time. It returns
+// false if the rule is not yet in effect.
+func (d deadlines) apply(t target, now time.Time) (target, bool) {
+	if now.Before(d.disabledUntil) {
+		return target{}, false
+	}
+	switch {
+	case !d.errorFrom.IsZero() && !now.Before(d.errorFrom):
+		t.severity = SeverityError
+	case !d.warnFrom.IsZero() && !now.Before(d.warnFrom):
+		t.severity = SeverityWarning
+	case !d.warnFrom.IsZero():
+		t.severity = SeverityInfo
+	case !d.errorFrom.IsZero():
+		t.severity = SeverityWarning
+	}
+	if !d.errorFrom.IsZero() && now.Before(d.errorFrom) {
+		t.forbiddenFrom = d.errorFrom
+	}
+	return t, true
+}
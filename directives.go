@@ -0,0 +1,208 @@
+package anathema
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Findings can be suppressed with directives in comments, each of which has to explain why it is
+// needed:
+//
+//   //anathema:ignore <ids> -- <reason>
+//   //anathema:file-ignore <ids> -- <reason>
+//   //anathema:package-ignore <ids> -- <reason>
+//   //nolint:anathema // <reason>
+//
+// The ids are a comma-separated list of the packages and symbols that are reported, e.g 'database/sql'
+// or 'os.Exit', or of the IDs of the rules that report them. An ignore directive that trails code
+// applies to its own line while one on a line of its own applies to the statement or declaration that
+// follows it, including any block that it contains. The file and package variants apply to the entire
+// file or package. The nolint directives of golangci-lint apply to all findings when they name
+// anathema, or when they do not name any linter at all. Directives without a reason, as well as
+// directives that do not suppress anything, are reported in turn.
+
+type directiveScope int
+
+const (
+	scopeLines directiveScope = iota
+	scopeFile
+	scopePackage
+)
+
+type directive struct {
+	comment  *ast.Comment
+	name     string   // Name under which the directive is reported.
+	ids      []string // Subjects that are suppressed, or all of them if empty.
+	reason   string
+	problem  string // Reason why the directive is malformed, if it is.
+	scope    directiveScope
+	file     string
+	from, to int // Lines to which a directive with scopeLines applies.
+
+	needsReason bool
+	needsUse    bool // Whether the directive is known to be unused when it does not suppress anything.
+	used        bool
+}
+
+var (
+	ignoreDirectiveRE = regexp.MustCompile(`^//anathema:(ignore|file-ignore|package-ignore)(?:\s+(.*))?$`)
+	nolintDirectiveRE = regexp.MustCompile(`^//nolint(?::([\w-]+(?:,[\w-]+)*))?(?:\s+(.*))?$`)
+)
+
+func (d *directive) covers(f finding, pos token.Position) bool {
+	if len(d.ids) > 0 {
+		var listed bool
+		for _, id := range d.ids {
+			listed = listed || id == f.subject || (f.rule != "" && id == f.rule)
+		}
+		if !listed {
+			return false
+		}
+	}
+	switch d.scope {
+	case scopePackage:
+		return true
+	case scopeFile:
+		return pos.Filename == d.file
+	default:
+		return pos.Filename == d.file && d.from <= pos.Line && pos.Line <= d.to
+	}
+}
+
+// reportDirectives reports the directives that are malformed, that do not give a reason or that do not
+// suppress any finding.
+func (r *reporter) reportDirectives() {
+	for _, d := range r.directives {
+		var message string
+		switch {
+		case d.problem != "":
+			message = fmt.Sprintf("%s directive %s", d.name, d.problem)
+		case d.needsReason && d.reason == "":
+			message = fmt.Sprintf("%s directive should explain why it is needed", d.name)
+		case d.needsUse && !d.used:
+			message = fmt.Sprintf("%s directive does not suppress any finding", d.name)
+		default:
+			continue
+		}
+		r.report(finding{Diagnostic: analysis.Diagnostic{Pos: d.comment.Pos(), End: d.comment.End(), Message: message}})
+	}
+}
+
+func parseDirectives(fset *token.FileSet, file *ast.File) []*directive {
+	var directives []*directive
+	var lines *directiveLines
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			d := parseDirective(c)
+			if d == nil {
+				continue
+			}
+			d.file = fset.Position(c.Pos()).Filename
+			if d.scope == scopeLines {
+				if lines == nil {
+					lines = newDirectiveLines(fset, file)
+				}
+				d.from, d.to = lines.span(group, c)
+			}
+			directives = append(directives, d)
+		}
+	}
+	return directives
+}
+
+func parseDirective(c *ast.Comment) *directive {
+	if m := ignoreDirectiveRE.FindStringSubmatch(c.Text); m != nil {
+		d := &directive{comment: c, name: "anathema:" + m[1], needsReason: true, needsUse: true}
+		switch m[1] {
+		case "file-ignore":
+			d.scope = scopeFile
+		case "package-ignore":
+			d.scope = scopePackage
+		}
+
+		var ids string
+		if fields := strings.Fields(m[2]); len(fields) > 0 && fields[0] != "--" {
+			ids = fields[0]
+		}
+		if idx := strings.Index(m[2], "--"); idx >= 0 {
+			d.reason = strings.TrimSpace(m[2][idx+2:])
+		}
+		for _, id := range strings.Split(ids, ",") {
+			if id != "" {
+				d.ids = append(d.ids, id)
+			}
+		}
+		if len(d.ids) == 0 {
+			d.problem = "should list the packages or symbols that it suppresses"
+		}
+		return d
+	}
+
+	if m := nolintDirectiveRE.FindStringSubmatch(c.Text); m != nil {
+		d := &directive{comment: c, name: "nolint"}
+		if reason := strings.TrimSpace(m[2]); strings.HasPrefix(reason, "//") {
+			d.reason = strings.TrimSpace(strings.TrimPrefix(reason, "//"))
+		}
+		if m[1] == "" {
+			return d
+		}
+		linters := strings.Split(m[1], ",")
+		for _, linter := range linters {
+			switch linter {
+			case "anathema":
+				// Directives that also target other linters may be needed by those linters.
+				d.needsReason, d.needsUse = true, len(linters) == 1
+				return d
+			case "all":
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// directiveLines determines the lines to which directives apply based on the code around them.
+type directiveLines struct {
+	fset   *token.FileSet
+	starts map[int]ast.Node  // Outermost node that starts on each line.
+	first  map[int]token.Pos // First position at which code starts or ends on each line.
+}
+
+func newDirectiveLines(fset *token.FileSet, file *ast.File) *directiveLines {
+	l := &directiveLines{fset: fset, starts: map[int]ast.Node{}, first: map[int]token.Pos{}}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.File, *ast.CommentGroup, *ast.Comment:
+			return n != nil
+		}
+		start := fset.Position(n.Pos()).Line
+		if _, ok := l.starts[start]; !ok {
+			l.starts[start] = n
+		}
+		for _, pos := range []token.Pos{n.Pos(), n.End() - 1} {
+			line := fset.Position(pos).Line
+			if first, ok := l.first[line]; !ok || pos < first {
+				l.first[line] = pos
+			}
+		}
+		return true
+	})
+	return l
+}
+
+func (l *directiveLines) span(group *ast.CommentGroup, c *ast.Comment) (int, int) {
+	line := l.fset.Position(c.Pos()).Line
+	if first, ok := l.first[line]; ok && first < c.Pos() {
+		return line, line
+	}
+	next := l.fset.Position(group.End()).Line + 1
+	if n, ok := l.starts[next]; ok {
+		return next, l.fset.Position(n.End()).Line
+	}
+	return next, next
+}
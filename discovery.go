@@ -0,0 +1,92 @@
+package anathema
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Without an explicit configuration each package is analysed with the configuration files that are
+// found in its directory and in the parent directories up to the root of its module or, outside of a
+// module, up to the root of its repository or the src directory of its GOPATH entry, whichever comes
+// first. Files above those directories, e.g in the home directory, are never used. Nested files refine
+// those of their parents: their rules take precedence over the rules of a parent that list the same
+// package or symbol and replace any rule of a parent with the same ID, while packages and symbols are
+// whitelisted, and indirect types detected, if any of the files says so.
+
+const configFileName = ".anathema.yml"
+
+// configCache holds the configurations that apply to the directories that have been analysed so far.
+type configCache struct {
+	mu       sync.Mutex
+	resolved map[string]*Configuration // Nil for directories without any configuration.
+}
+
+// discover returns the configuration that applies to the package of the given pass.
+func (cc *configCache) discover(pass *analysis.Pass) (*Configuration, error) {
+	if len(pass.Files) == 0 {
+		return nil, fmt.Errorf("no configuration was specified for package %s which has no files to locate one with", pass.Pkg.Path())
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Package).Name())
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	c, err := cc.resolve(dir)
+	if err != nil {
+		return nil, err
+	} else if c == nil {
+		return nil, fmt.Errorf("no configuration was specified and no %s file was found in %q or its parents", configFileName, dir)
+	}
+	return c, nil
+}
+
+func (cc *configCache) resolve(dir string) (*Configuration, error) {
+	if c, ok := cc.resolved[dir]; ok {
+		return c, nil
+	}
+
+	var c *Configuration
+	if !isDiscoveryRoot(dir) {
+		if parent := filepath.Dir(dir); parent != dir {
+			var err error
+			if c, err = cc.resolve(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	path := filepath.Join(dir, configFileName)
+	if _, err := os.Stat(path); err == nil {
+		nested, err := LoadConfiguration(path)
+		if err != nil {
+			return nil, err
+		}
+		c = c.merge(nested)
+	}
+
+	if cc.resolved == nil {
+		cc.resolved = map[string]*Configuration{}
+	}
+	cc.resolved[dir] = c
+	return c, nil
+}
+
+// isDiscoveryRoot reports whether the discovery of configuration files stops at the given directory,
+// i.e whether it is the root of a module or of a repository, or the src directory of a GOPATH entry.
+func isDiscoveryRoot(dir string) bool {
+	for _, marker := range []string{"go.mod", ".git", ".hg", ".svn", ".bzr"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		if gopath != "" && filepath.Clean(dir) == filepath.Join(gopath, "src") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package anathema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryStopsAtRepository(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "anathema")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repo := filepath.Join(dir, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "pkg"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, configFileName), []byte("packages:\n  whitelist: true\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo, configFileName), []byte("packages:\n  rules:\n    - path: io/ioutil\n"), 0644))
+
+	cc := &configCache{}
+	c, err := cc.resolve(filepath.Join(repo, "pkg"))
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.False(t, c.Packages.Whitelist)
+	require.Len(t, c.Packages.Rules, 1)
+	assert.Equal(t, "io/ioutil", c.Packages.Rules[0].Path)
+	assert.NotContains(t, cc.resolved, dir)
+}
@@ -0,0 +1,446 @@
+package anathema
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// importEditor keeps track of the import changes that are required by the suggested fixes emitted for
+// a single file. As several fixes may require the same import to be added or removed, the resulting
+// edits are only computed once all fixes for the file are known.
+type importEditor struct {
+	pass *analysis.Pass
+	file *ast.File
+
+	names    map[string]string // Import path -> local name used within the file.
+	added    map[string]bool   // Newly imported path -> whether it requires an explicit name.
+	taken    map[string]bool   // Identifiers that can not be used as a new import name.
+	reserved map[string]bool   // New import names that have been handed out.
+}
+
+func newImportEditor(pass *analysis.Pass, file *ast.File) *importEditor {
+	e := &importEditor{
+		pass:     pass,
+		file:     file,
+		names:    map[string]string{},
+		added:    map[string]bool{},
+		taken:    map[string]bool{},
+		reserved: map[string]bool{},
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			e.taken[id.Name] = true
+		}
+		return true
+	})
+	for _, name := range pass.Pkg.Scope().Names() {
+		e.taken[name] = true
+	}
+	return e
+}
+
+// localName returns the name by which the package at the given path can be referenced from within
+// the file. If the package is not yet imported by the file a new, non-clashing, name is reserved.
+func (e *importEditor) localName(path string) string {
+	if path == e.pass.Pkg.Path() {
+		return ""
+	} else if name, ok := e.names[path]; ok {
+		return name
+	}
+
+	for _, imp := range e.file.Imports {
+		if importPath(imp) != path {
+			continue
+		}
+		if pkgName := e.importedName(imp); pkgName != nil {
+			e.names[path] = pkgName.Name()
+			return pkgName.Name()
+		}
+	}
+
+	name, explicit := e.newName(packageName(e.pass, path))
+	e.names[path] = name
+	e.added[path] = explicit
+	return name
+}
+
+// newName reserves a non-clashing local name for a package with the given name. The returned boolean
+// indicates whether the package needs to be imported with an explicit name in order to use it. The
+// predeclared identifiers are never used so that the new import does not shadow any of them.
+func (e *importEditor) newName(base string, known bool) (string, bool) {
+	name := base
+	for idx := 2; e.taken[name] || token.IsKeyword(name) || types.Universe.Lookup(name) != nil; idx++ {
+		name = fmt.Sprintf("%s%d", base, idx)
+	}
+	e.taken[name] = true
+	e.reserved[name] = true
+	return name, !known || name != base
+}
+
+// clone returns a copy of the editor that reserves names independently of the original.
+func (e *importEditor) clone() *importEditor {
+	c := &importEditor{
+		pass:     e.pass,
+		file:     e.file,
+		names:    map[string]string{},
+		added:    map[string]bool{},
+		taken:    map[string]bool{},
+		reserved: map[string]bool{},
+	}
+	for path, name := range e.names {
+		c.names[path] = name
+	}
+	for path, explicit := range e.added {
+		c.added[path] = explicit
+	}
+	for name := range e.taken {
+		c.taken[name] = true
+	}
+	for name := range e.reserved {
+		c.reserved[name] = true
+	}
+	return c
+}
+
+// release makes the name of an import that the fixes remove available to the imports that they add,
+// unless other identifiers of the file use the name or it has been handed out already.
+func (e *importEditor) release(pkgName *types.PkgName) {
+	name := pkgName.Name()
+	if e.reserved[name] || e.pass.Pkg.Scope().Lookup(name) != nil {
+		return
+	}
+	var used bool
+	ast.Inspect(e.file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if ok && id.Name == name && e.pass.TypesInfo.Uses[id] != pkgName && e.pass.TypesInfo.Defs[id] != pkgName {
+			used = true
+		}
+		return !used
+	})
+	if !used {
+		delete(e.taken, name)
+	}
+}
+
+func (e *importEditor) importedName(imp *ast.ImportSpec) *types.PkgName {
+	if imp.Name != nil {
+		if imp.Name.Name == "_" || imp.Name.Name == "." {
+			return nil
+		}
+		pkgName, _ := e.pass.TypesInfo.Defs[imp.Name].(*types.PkgName)
+		return pkgName
+	}
+	pkgName, _ := e.pass.TypesInfo.Implicits[imp].(*types.PkgName)
+	return pkgName
+}
+
+func (e *importEditor) importSpec(path string) string {
+	if e.added[path] {
+		return fmt.Sprintf("%s %q", e.names[path], path)
+	}
+	return strconv.Quote(path)
+}
+
+// attach adds the import edits that the suggested fixes of the given findings require. Each fix adds
+// the imports of the packages that it references so that it can be applied on its own, while the edits
+// that several fixes have in common are identical so that all fixes can be applied at once. The findings
+// that together rewrite all uses of an import in the file share the fix that rewrites all these uses
+// and removes the import.
+func (e *importEditor) attach(findings []finding) {
+	groups := e.groups(findings)
+	edits := make([][]analysis.TextEdit, len(findings))
+	for idx, f := range findings {
+		if len(f.SuggestedFixes) > 0 {
+			edits[idx] = append([]analysis.TextEdit(nil), f.SuggestedFixes[0].TextEdits...)
+		}
+	}
+
+	for idx := range findings {
+		f := &findings[idx]
+		if len(f.SuggestedFixes) == 0 {
+			continue
+		}
+		fix := &f.SuggestedFixes[0]
+		required := f.requires
+		if group, ok := groups[f.rewrites]; ok {
+			fix.TextEdits, required = nil, nil
+			for _, member := range group {
+				fix.TextEdits = append(fix.TextEdits, edits[member]...)
+				required = append(required, findings[member].requires...)
+			}
+			switch others := len(group) - 1; {
+			case others == 1:
+				fix.Message = fmt.Sprintf("%s, along with the other use of %s", fix.Message, f.rewrites.Imported().Path())
+			case others > 1:
+				fix.Message = fmt.Sprintf("%s, along with the %d other uses of %s", fix.Message, others, f.rewrites.Imported().Path())
+			}
+			fix.TextEdits = append(fix.TextEdits, e.removeImport(f.rewrites)...)
+		}
+		fix.TextEdits = sortEdits(append(fix.TextEdits, e.addImports(required)...))
+	}
+}
+
+// groups returns the indices of the findings with fixes that together rewrite all uses of an import in
+// the file, by the imported package.
+func (e *importEditor) groups(findings []finding) map[*types.PkgName][]int {
+	groups := map[*types.PkgName][]int{}
+	for idx, f := range findings {
+		if len(f.SuggestedFixes) > 0 && f.rewrites != nil {
+			groups[f.rewrites] = append(groups[f.rewrites], idx)
+		}
+	}
+	for pkgName, group := range groups {
+		if len(group) != countUses(e.pass, e.file, pkgName) {
+			delete(groups, pkgName)
+		}
+	}
+	return groups
+}
+
+// anchor returns the import declaration to which new imports are added.
+func (e *importEditor) anchor() *ast.GenDecl {
+	var last *ast.GenDecl
+	for _, decl := range e.file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			last = gen
+		}
+	}
+	return last
+}
+
+// addImports returns the edits that import the given packages if they are not yet imported by the file.
+// Each import is added by an edit of its own so that fixes that add the same import share that edit.
+func (e *importEditor) addImports(paths []string) []analysis.TextEdit {
+	var specs []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if _, ok := e.added[path]; ok && !seen[path] {
+			seen[path] = true
+			specs = append(specs, e.importSpec(path))
+		}
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	sort.Strings(specs)
+
+	var edits []analysis.TextEdit
+	anchor := e.anchor()
+	switch {
+	case anchor == nil:
+		for _, spec := range specs {
+			edits = append(edits, analysis.TextEdit{Pos: e.file.Name.End(), End: e.file.Name.End(), NewText: []byte("\n\nimport " + spec)})
+		}
+	case anchor.Lparen.IsValid():
+		prefix := "\t"
+		if tf := e.pass.Fset.File(anchor.Rparen); tf.LineStart(tf.Line(anchor.Rparen)) != anchor.Rparen {
+			prefix = "\n\t"
+		}
+		for _, spec := range specs {
+			edits = append(edits, analysis.TextEdit{Pos: anchor.Rparen, End: anchor.Rparen, NewText: []byte(prefix + spec + "\n")})
+		}
+	default:
+		// The declaration of a single import is turned into a parenthesised one.
+		imp := anchor.Specs[0]
+		for _, spec := range specs {
+			edits = append(edits, analysis.TextEdit{Pos: imp.Pos(), End: imp.Pos(), NewText: []byte(spec + "\n\t")})
+		}
+		edits = append(edits, parenthesise(anchor)...)
+	}
+	return edits
+}
+
+// removeImport returns the edits that remove the import of the given package. The declaration to which
+// new imports are added is retained, even if empty, so that the edits do not conflict with these.
+func (e *importEditor) removeImport(pkgName *types.PkgName) []analysis.TextEdit {
+	for _, decl := range e.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if e.importedName(imp) != pkgName {
+				continue
+			}
+			retained := gen == e.anchor() && len(e.added) > 0
+			switch {
+			case len(gen.Specs) > 1 || (retained && gen.Lparen.IsValid()):
+				return []analysis.TextEdit{deleteLines(e.pass.Fset, imp.Pos(), imp.End())}
+			case retained:
+				return append(parenthesise(gen), analysis.TextEdit{Pos: imp.Pos(), End: imp.End()})
+			default:
+				return []analysis.TextEdit{deleteLines(e.pass.Fset, gen.Pos(), gen.End())}
+			}
+		}
+	}
+	return nil
+}
+
+// parenthesise returns the edits that turn the declaration of a single import into a parenthesised one.
+func parenthesise(decl *ast.GenDecl) []analysis.TextEdit {
+	keyword := decl.TokPos + token.Pos(len(token.IMPORT.String()))
+	return []analysis.TextEdit{
+		{Pos: decl.TokPos, End: keyword, NewText: []byte("import (")},
+		{Pos: decl.End(), End: decl.End(), NewText: []byte("\n)")},
+	}
+}
+
+// sortEdits orders the edits of a fix by position and drops the duplicate ones.
+func sortEdits(edits []analysis.TextEdit) []analysis.TextEdit {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Pos != edits[j].Pos {
+			return edits[i].Pos < edits[j].Pos
+		}
+		return edits[i].End < edits[j].End
+	})
+	var sorted []analysis.TextEdit
+	for idx, edit := range edits {
+		if prev := idx - 1; prev >= 0 && edit.Pos == edits[prev].Pos && edit.End == edits[prev].End && string(edit.NewText) == string(edits[prev].NewText) {
+			continue
+		}
+		sorted = append(sorted, edit)
+	}
+	return sorted
+}
+
+// deleteLines returns an edit that removes the full lines that span the given range.
+func deleteLines(fset *token.FileSet, pos token.Pos, end token.Pos) analysis.TextEdit {
+	tf := fset.File(pos)
+	start := tf.LineStart(tf.Line(pos))
+	stop := tf.Pos(tf.Size())
+	if line := tf.Line(end); line < tf.LineCount() {
+		stop = tf.LineStart(line + 1)
+	}
+	return analysis.TextEdit{Pos: start, End: stop}
+}
+
+func countUses(pass *analysis.Pass, file *ast.File, obj types.Object) int {
+	var count int
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == obj {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+func importPath(imp *ast.ImportSpec) string {
+	path, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return strings.Trim(imp.Path.Value, `"`)
+	}
+	return path
+}
+
+var majorVersionRE = regexp.MustCompile(`^v[0-9]+$`)
+
+// packageName returns the declared name of the package at the given import path. It is looked up in
+// the dependencies of the analysed package and otherwise in the sources of the package. If these can
+// not be found either the name is derived from the import path, in which case the returned boolean is
+// false.
+func packageName(pass *analysis.Pass, path string) (string, bool) {
+	seen := map[*types.Package]bool{}
+	var find func(*types.Package) *types.Package
+	find = func(p *types.Package) *types.Package {
+		if seen[p] {
+			return nil
+		}
+		seen[p] = true
+		for _, imp := range p.Imports() {
+			if imp.Path() == path {
+				return imp
+			} else if found := find(imp); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if found := find(pass.Pkg); found != nil {
+		return found.Name(), true
+	}
+	for _, dir := range packageDirs(pass, path) {
+		if name := declaredName(dir); name != "" {
+			return name, true
+		}
+	}
+	return guessPackageName(path), false
+}
+
+// packageDirs returns the directories that may hold the sources of the package at the given import
+// path: within GOROOT for the standard library and, as packages of the same GOPATH or module are laid
+// out according to their import paths, relative to the directory of the analysed package.
+func packageDirs(pass *analysis.Pass, path string) []string {
+	var dirs []string
+	if build.Default.GOROOT != "" {
+		dirs = append(dirs, filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	}
+	if len(pass.Files) == 0 {
+		return dirs
+	}
+
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Package).Name())
+	from, to := strings.Split(importerPath(pass.Pkg), "/"), strings.Split(path, "/")
+	var common int
+	for common < len(from) && common < len(to) && from[common] == to[common] {
+		common++
+	}
+	for idx := len(from) - 1; idx >= common; idx-- {
+		if filepath.Base(dir) != from[idx] {
+			return dirs // The directory of the analysed package does not match its import path.
+		}
+		dir = filepath.Dir(dir)
+	}
+	return append(dirs, filepath.Join(append([]string{dir}, to[common:]...)...))
+}
+
+// declaredName returns the name that is declared by the non-test Go files in the given directory.
+func declaredName(dir string) string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	fset := token.NewFileSet()
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly); err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
+
+// guessPackageName derives the most likely name of a package from its import path.
+func guessPackageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if majorVersionRE.MatchString(name) && len(elems) > 1 {
+		name = elems[len(elems)-2]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || ('0' <= name[0] && name[0] <= '9') {
+		name = "pkg" + name
+	}
+	return name
+}
@@ -0,0 +1,237 @@
+package anathema
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// checkTypes flags the values and variables whose type is, or contains, a forbidden type. Expressions
+// and declarations that name a forbidden type explicitly are already flagged by checkSymbols and are
+// skipped here, as are the values that initialise a flagged variable so that each site is only
+// reported once.
+func checkTypes(pass *analysis.Pass, c *configuration, r *reporter, file *ast.File) {
+	importer := importerPath(pass.Pkg)
+	// The forbidden type that was last matched and the target of the rule that forbids it.
+	var subject string
+	var matched target
+	forbidden := func(named *types.Named) bool {
+		obj := named.Obj()
+		if obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+			return false
+		}
+		path := vendorlessPath(obj.Pkg().Path())
+		// Symbol rules take precedence over package rules.
+		st, symbolDenied, symbolMatched := c.symbolTargetIn(importer, path, func(pkg string) string { return pkg + "." + obj.Name() })
+		pt, packageDenied := c.packageTargetIn(importer, path)
+		switch {
+		case symbolDenied:
+			matched = st
+		case !symbolMatched && packageDenied:
+			matched = pt
+		default:
+			return false
+		}
+		subject = path + "." + obj.Name()
+		return true
+	}
+	report := func(node ast.Node, format string, args ...interface{}) {
+		d := finding{
+			Diagnostic: analysis.Diagnostic{
+				Pos:      node.Pos(),
+				End:      node.End(),
+				Category: matched.severity,
+				Message:  withURL(fmt.Sprintf(format, args...), matched),
+			},
+			subject: subject,
+			rule:    matched.id,
+		}
+		if !r.suppressed(d) {
+			r.report(d)
+		}
+	}
+	contains := func(t types.Type) bool {
+		return containsType(t, forbidden, pass.Pkg, map[*types.Named]bool{})
+	}
+	typeString := func(t types.Type) string {
+		s := types.TypeString(unalias(t), func(pkg *types.Package) string { return vendorlessPath(pkg.Path()) })
+		if !strings.Contains(s, subject) {
+			// The forbidden type is part of the underlying type of a type of the analysed package.
+			s = fmt.Sprintf("%s, which contains %s,", s, subject)
+		}
+		return s
+	}
+
+	checkVar := func(id *ast.Ident, typeExpr ast.Expr) bool {
+		v, ok := pass.TypesInfo.Defs[id].(*types.Var)
+		if !ok || id.Name == "_" || !contains(v.Type()) || namesType(pass, typeExpr, forbidden) {
+			return false
+		}
+		kind := "variable"
+		if v.IsField() {
+			kind = "field"
+		}
+		report(id, "%s %s of type %s should not be used", kind, id.Name, typeString(v.Type()))
+		return true
+	}
+	// The values that initialise flagged variables are not reported in addition to these.
+	covered := map[ast.Expr]bool{}
+	checkVars := func(names []ast.Expr, typeExpr ast.Expr, values ...ast.Expr) {
+		var reported bool
+		for _, name := range names {
+			if id, ok := name.(*ast.Ident); ok && checkVar(id, typeExpr) {
+				reported = true
+			}
+		}
+		for _, value := range values {
+			if value != nil && reported {
+				covered[unparen(value)] = true
+			}
+		}
+	}
+
+	called := map[ast.Expr]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			for _, name := range n.Names {
+				checkVar(name, n.Type)
+			}
+		case *ast.ValueSpec:
+			names := make([]ast.Expr, 0, len(n.Names))
+			for _, name := range n.Names {
+				names = append(names, name)
+			}
+			checkVars(names, n.Type, n.Values...)
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				checkVars(n.Lhs, nil, n.Rhs...)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				checkVars([]ast.Expr{n.Key, n.Value}, nil, n.X)
+			}
+		}
+
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+		switch e := expr.(type) {
+		case *ast.Ident, *ast.ParenExpr:
+			return true
+		case *ast.CompositeLit:
+			if namesType(pass, e.Type, forbidden) {
+				return true
+			}
+		case *ast.CallExpr:
+			called[e.Fun] = true
+			if tv, ok := pass.TypesInfo.Types[e.Fun]; ok && tv.IsType() && namesType(pass, e.Fun, forbidden) {
+				return true // Conversions name the resulting type explicitly.
+			}
+		}
+
+		tv, ok := pass.TypesInfo.Types[expr]
+		if !ok || !tv.IsValue() || called[expr] || covered[expr] || !contains(tv.Type) {
+			return true
+		}
+		report(expr, "value of type %s should not be used", typeString(tv.Type))
+		return true
+	})
+}
+
+// containsType reports whether the given type is, or is composed of, a named type that matches the
+// given predicate. The underlying types of the named types declared by the given package are inspected
+// as well, keeping track of those that were already seen to handle recursive types, contrary to those of
+// the named types of other packages.
+func containsType(t types.Type, match func(*types.Named) bool, local *types.Package, seen map[*types.Named]bool) bool {
+	switch t := unalias(t).(type) {
+	case *types.Named:
+		if match(t) {
+			return true
+		} else if t.Obj().Pkg() != local || seen[t] {
+			return false
+		}
+		seen[t] = true
+		return containsType(t.Underlying(), match, local, seen)
+	case *types.Pointer:
+		return containsType(t.Elem(), match, local, seen)
+	case *types.Slice:
+		return containsType(t.Elem(), match, local, seen)
+	case *types.Array:
+		return containsType(t.Elem(), match, local, seen)
+	case *types.Chan:
+		return containsType(t.Elem(), match, local, seen)
+	case *types.Map:
+		return containsType(t.Key(), match, local, seen) || containsType(t.Elem(), match, local, seen)
+	case *types.Signature:
+		return containsType(t.Params(), match, local, seen) || containsType(t.Results(), match, local, seen)
+	case *types.Tuple:
+		for idx := 0; idx < t.Len(); idx++ {
+			if containsType(t.At(idx).Type(), match, local, seen) {
+				return true
+			}
+		}
+	case *types.Struct:
+		for idx := 0; idx < t.NumFields(); idx++ {
+			if containsType(t.Field(idx).Type(), match, local, seen) {
+				return true
+			}
+		}
+	case *types.Interface:
+		for idx := 0; idx < t.NumMethods(); idx++ {
+			if containsType(t.Method(idx).Type(), match, local, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namesType reports whether the given type expression explicitly references a named type that
+// matches the given predicate.
+func namesType(pass *analysis.Pass, expr ast.Expr, match func(*types.Named) bool) bool {
+	if expr == nil {
+		return false
+	}
+	var found bool
+	ast.Inspect(expr, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || found {
+			return !found
+		}
+		if tn, ok := pass.TypesInfo.Uses[id].(*types.TypeName); ok && !tn.IsAlias() {
+			if named, ok := tn.Type().(*types.Named); ok && match(named) {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
+
+// unalias resolves type aliases on toolchains where go/types represents them explicitly. The alias type
+// is matched through its method set so that this remains compatible with older versions of go/types.
+func unalias(t types.Type) types.Type {
+	for {
+		alias, ok := t.(interface{ Rhs() types.Type })
+		if !ok {
+			return t
+		}
+		t = alias.Rhs()
+	}
+}
@@ -0,0 +1,139 @@
+package anathema
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Allow-lists may trust the standard library and the main module so that they only need to list
+// third-party dependencies. The packages of the standard library are those found in GOROOT while the
+// main module of a package is declared by the closest go.mod file in its directory or its parents.
+
+var (
+	loadStd sync.Once
+	std     map[string]bool
+)
+
+// isStdPackage reports whether the given package is part of the standard library.
+func isStdPackage(pkg string) bool {
+	loadStd.Do(func() { std = stdPackages(build.Default.GOROOT) })
+	if len(std) == 0 {
+		// Without a GOROOT to list the standard library from, its packages are recognised by the lack
+		// of a domain name in their first path element.
+		return !strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".")
+	}
+	return std[pkg]
+}
+
+func stdPackages(goroot string) map[string]bool {
+	packages := map[string]bool{}
+	if goroot == "" {
+		return packages
+	}
+	root := filepath.Join(goroot, "src")
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+			packages[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	return packages
+}
+
+// moduleCache holds the path of the main module of the directories that have been analysed so far.
+type moduleCache struct {
+	mu       sync.Mutex
+	resolved map[string]string // Empty for directories outside of any module.
+}
+
+// mainModule returns the path of the module that contains the package of the given pass, if any.
+func (mc *moduleCache) mainModule(pass *analysis.Pass) string {
+	if len(pass.Files) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Package).Name())
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.resolve(dir)
+}
+
+func (mc *moduleCache) resolve(dir string) string {
+	if path, ok := mc.resolved[dir]; ok {
+		return path
+	}
+
+	var path string
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		path = modulePath(data)
+	} else if parent := filepath.Dir(dir); parent != dir {
+		path = mc.resolve(parent)
+	}
+
+	if mc.resolved == nil {
+		mc.resolved = map[string]string{}
+	}
+	mc.resolved[dir] = path
+	return path
+}
+
+// modulePath returns the path of the module directive of a go.mod file.
+func modulePath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if path, err := strconv.Unquote(fields[1]); err == nil {
+			return path
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// inModule reports whether the package belongs to the module with the given path.
+func inModule(module string, pkg string) bool {
+	return module != "" && (pkg == module || strings.HasPrefix(pkg, module+"/"))
+}
+
+// match reports whether the package matches the path, pattern or selector, resolving the selectors that
+// depend on the main module of the analysed package.
+func (c *configuration) match(pattern string, pkg string) bool {
+	switch pattern {
+	case "@main-module":
+		return inModule(c.mainModule, pkg)
+	case "@thirdparty":
+		return c.mainModule != "" && !inModule(c.mainModule, pkg) && !isStdPackage(pkg) && pkg != "C"
+	default:
+		return matchPattern(pattern, pkg)
+	}
+}
+
+// dependsOnModule reports whether the package rules match packages depending on the main module of the
+// analysed package.
+func (c *configuration) dependsOnModule() bool {
+	for _, pattern := range c.packagePatterns {
+		if pattern == "@main-module" || pattern == "@thirdparty" {
+			return true
+		}
+	}
+	return false
+}
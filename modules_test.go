@@ -0,0 +1,38 @@
+package anathema
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulePath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "github.com/foo/bar", modulePath([]byte("// Comment.\nmodule github.com/foo/bar // Trailing.\n\ngo 1.14\n")))
+	assert.Equal(t, "example.com/quoted", modulePath([]byte(`module "example.com/quoted"`)))
+	assert.Equal(t, "", modulePath([]byte("go 1.14\n")))
+}
+
+func TestMainModule(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("testdata", "modules", "root")
+	mc := &moduleCache{}
+	assert.Equal(t, "example.com/root", mc.resolve(root))
+	assert.Equal(t, "example.com/root", mc.resolve(filepath.Join(root, "nested", "pkg")))
+	assert.Equal(t, "example.com/root/sub", mc.resolve(filepath.Join(root, "sub", "pkg")))
+	assert.Equal(t, "example.com/root", mc.resolved[filepath.Join(root, "nested")])
+}
+
+func TestStdPackages(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[string]bool{
+		"fmt":      true,
+		"net":      true,
+		"net/http": true,
+	}, stdPackages(filepath.Join("testdata", "modules", "goroot")))
+	assert.Empty(t, stdPackages(""))
+}
@@ -0,0 +1,381 @@
+package anathema
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Package paths in rules may be patterns. A '...' final element matches the package itself as well as
+// all packages below it, e.g 'github.com/aws/aws-sdk-go/...'. Any other element may be a glob that
+// matches a single path element, e.g 'k8s.io/api/*/v1'. When several patterns match the same package
+// the most specific one is used: exact paths first, then the patterns with the most literal elements,
+// then patterns that match a fixed number of elements over subtree patterns and finally the longest
+// pattern.
+//
+// Wherever a package pattern is accepted a selector may also match a class of packages:
+//
+//   - '@module:<path>' matches the packages of the given module, e.g '@module:github.com/foo/bar'.
+//   - '@cgo' matches the 'C' pseudo-package that is imported by packages that use cgo.
+//   - '@internal' matches the packages with an 'internal' path element.
+//   - '@main-module' matches the packages of the module of the analysed package.
+//   - '@std' matches the packages of the standard library.
+//   - '@thirdparty' matches all other packages, except for cgo's pseudo-package.
+//
+// As the main module is only known when analysing a package that belongs to one, '@main-module' and
+// '@thirdparty' do not match any package otherwise. Selectors take precedence after all paths and
+// patterns, in the order in which they are listed above, except for '...' on its own which matches all
+// packages and therefore comes last.
+
+const moduleSelector = "@module:"
+
+// selectors maps the selectors to their precedence, from the narrowest class of packages to the widest.
+// Selectors of modules come first.
+var selectors = map[string]int{
+	"@cgo":         1,
+	"@internal":    2,
+	"@main-module": 3,
+	"@std":         4,
+	"@thirdparty":  5,
+}
+
+func selectorRank(pattern string) int {
+	if strings.HasPrefix(pattern, moduleSelector) {
+		return 0
+	}
+	return selectors[pattern]
+}
+
+func isSelector(pattern string) bool {
+	return strings.HasPrefix(pattern, "@")
+}
+
+func isPattern(pattern string) bool {
+	return isSelector(pattern) || isSubtree(pattern) || strings.ContainsAny(pattern, "*?[")
+}
+
+func isSubtree(pattern string) bool {
+	return pattern == "..." || strings.HasSuffix(pattern, "/...")
+}
+
+func validatePattern(pattern string) error {
+	if strings.HasPrefix(pattern, moduleSelector) {
+		module := strings.TrimPrefix(pattern, moduleSelector)
+		if isPattern(module) {
+			return fmt.Errorf("%q needs to name a module path without patterns", pattern)
+		}
+		return validatePattern(module)
+	} else if _, ok := selectors[pattern]; isSelector(pattern) && !ok {
+		return fmt.Errorf("%q is not a known selector, expected one of '@std', '@main-module', '@thirdparty', '@module:<path>', '@internal' or '@cgo'", pattern)
+	} else if isSelector(pattern) {
+		return nil
+	}
+
+	elems := strings.Split(pattern, "/")
+	for idx, elem := range elems {
+		if strings.Contains(elem, "...") && (elem != "..." || idx != len(elems)-1) {
+			return fmt.Errorf("%q may only contain '...' as its final path element", pattern)
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("%q contains a malformed glob in %q", pattern, elem)
+		}
+		if (isSelector(elem) || !isPattern(elem)) && !validPathElement(elem) {
+			return fmt.Errorf("%q is not a valid import path as %q is not a valid path element", pattern, elem)
+		}
+	}
+	return nil
+}
+
+// validPathElement reports whether the element may appear in an import path, following the same rules
+// as the go command: it is not empty, does not start or end with a dot and only contains letters,
+// digits and '-', '.', '_', '~' or '+'.
+func validPathElement(elem string) bool {
+	if elem == "" || elem[0] == '.' || elem[len(elem)-1] == '.' {
+		return false
+	}
+	for _, r := range elem {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', strings.ContainsRune("-._~+", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validateReplacementPattern checks that a replacement is only a pattern if it replaces a subtree of a
+// literal path with another subtree of a literal path.
+func validateReplacementPattern(pattern string, replacement string) error {
+	if !isPattern(replacement) {
+		return nil
+	}
+	for _, p := range []string{pattern, replacement} {
+		if !isSubtree(p) || isPattern(strings.TrimSuffix(p, "/...")) {
+			return fmt.Errorf("%q can only be replaced by %q if both are subtrees of literal paths", pattern, replacement)
+		}
+	}
+	return nil
+}
+
+// splitPattern returns the path elements of the pattern that precede an eventual '...' element.
+func splitPattern(pattern string) ([]string, bool) {
+	switch {
+	case pattern == "...":
+		return nil, true
+	case isSubtree(pattern):
+		return strings.Split(strings.TrimSuffix(pattern, "/..."), "/"), true
+	default:
+		return strings.Split(pattern, "/"), false
+	}
+}
+
+// matchPattern reports whether the package matches the path or pattern. Selectors that depend on the
+// main module are matched by configuration.match instead.
+func matchPattern(pattern string, pkg string) bool {
+	switch {
+	case strings.HasPrefix(pattern, moduleSelector):
+		return inModule(strings.TrimPrefix(pattern, moduleSelector), pkg)
+	case pattern == "@std":
+		return isStdPackage(pkg)
+	case pattern == "@internal":
+		return strings.Contains("/"+pkg+"/", "/internal/")
+	case pattern == "@cgo":
+		return pkg == "C"
+	case isSelector(pattern):
+		return false
+	case !isPattern(pattern):
+		return pattern == pkg
+	}
+	elems, subtree := splitPattern(pattern)
+	pkgElems := strings.Split(pkg, "/")
+	if len(pkgElems) < len(elems) || (!subtree && len(pkgElems) != len(elems)) {
+		return false
+	}
+	for idx, elem := range elems {
+		if ok, _ := path.Match(elem, pkgElems[idx]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// overlappingPatterns reports whether there may be a package that is matched by both patterns.
+func overlappingPatterns(a string, b string) bool {
+	if isSelector(a) || isSelector(b) {
+		return true
+	}
+	elemsA, subtreeA := splitPattern(a)
+	elemsB, subtreeB := splitPattern(b)
+	switch {
+	case len(elemsA) < len(elemsB) && !subtreeA, len(elemsB) < len(elemsA) && !subtreeB:
+		return false
+	case len(elemsA) != len(elemsB) && !subtreeA && !subtreeB:
+		return false
+	}
+	for idx := 0; idx < len(elemsA) && idx < len(elemsB); idx++ {
+		x, y := elemsA[idx], elemsB[idx]
+		globX, globY := isPattern(x), isPattern(y)
+		matchXY, _ := path.Match(x, y)
+		matchYX, _ := path.Match(y, x)
+		if !(x == y || (globX && globY) || (globX && matchXY) || (globY && matchYX)) {
+			return false
+		}
+	}
+	return true
+}
+
+// morePrecise reports whether pattern a takes precedence over pattern b.
+func morePrecise(a string, b string) bool {
+	if (a == "...") != (b == "...") {
+		return b == "..."
+	}
+	if isPattern(a) != isPattern(b) {
+		return !isPattern(a)
+	}
+	if isSelector(a) != isSelector(b) {
+		return !isSelector(a)
+	}
+	if rankA, rankB := selectorRank(a), selectorRank(b); rankA != rankB {
+		return rankA < rankB
+	}
+	literalsA, literalsB := literalElements(a), literalElements(b)
+	if literalsA != literalsB {
+		return literalsA > literalsB
+	}
+	if isSubtree(a) != isSubtree(b) {
+		return !isSubtree(a)
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a < b
+}
+
+func literalElements(pattern string) int {
+	elems, _ := splitPattern(pattern)
+	var count int
+	for _, elem := range elems {
+		if !isPattern(elem) {
+			count++
+		}
+	}
+	return count
+}
+
+// sortedPatterns returns the patterns among the given keys ordered by decreasing precedence.
+func sortedPatterns(keys []string) []string {
+	var patterns []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if isPattern(key) && !seen[key] {
+			seen[key] = true
+			patterns = append(patterns, key)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool { return morePrecise(patterns[i], patterns[j]) })
+	return patterns
+}
+
+// overlaps returns all pairs of packages or patterns that may match the same package, with the pattern
+// that takes precedence first.
+func overlaps(patterns []string) [][2]string {
+	sort.Slice(patterns, func(i, j int) bool { return morePrecise(patterns[i], patterns[j]) })
+
+	var pairs [][2]string
+	for i, a := range patterns {
+		for _, b := range patterns[i+1:] {
+			if (isPattern(a) || isPattern(b)) && overlappingPatterns(a, b) {
+				pairs = append(pairs, [2]string{a, b})
+			}
+		}
+	}
+	return pairs
+}
+
+// expandSubtree maps a package matched by a subtree pattern onto the equivalent package of the
+// replacement subtree, e.g 'github.com/old/foo' onto 'github.com/new/foo' for a rule that replaces
+// 'github.com/old/...' with 'github.com/new/...'. Other replacements are returned as is.
+func expandSubtree(pattern string, replacement string, pkg string) string {
+	if !isSubtree(pattern) || !isSubtree(replacement) {
+		return replacement
+	}
+	base := strings.TrimSuffix(replacement, "/...")
+	prefix := strings.TrimSuffix(pattern, "...")
+	if pkg+"/" == prefix {
+		return base
+	}
+	return base + "/" + strings.TrimPrefix(pkg, prefix)
+}
+
+// Symbol names in rules may be globs, e.g 'Must*', or regular expressions, e.g '^(Print|Fatal).*'.
+// Replacement names may refer to the capture groups of a regular expression, or to the wildcards of a
+// glob, as '$1' or '${name}'. Rules that list a name literally take precedence over those that match
+// it with a pattern, after which patterns are tried in the order in which they are configured.
+
+// namePattern is a symbol rule that matches the names of the symbols of a package, or of the methods
+// and fields of a type, with a regular expression.
+type namePattern struct {
+	owner           string // Package path or pattern, or '(pkg/path.Type)' for methods and fields.
+	raw             string
+	re              *regexp.Regexp
+	replacementPkg  string
+	replacementName string // Template that is expanded with the expression's capture groups.
+	base            target // Target of the rule without its replacement.
+}
+
+var groupReferenceRE = regexp.MustCompile(`\$(?:(\d+)|\{(\w+)\})`)
+
+func isNamePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+func newNamePattern(
+	owner string,
+	name string,
+	regex bool,
+	replacementPkg string,
+	replacementName string,
+	base target,
+) (namePattern, error) {
+	p := namePattern{
+		owner:          owner,
+		raw:            name,
+		replacementPkg: replacementPkg,
+		base:           base,
+		// A bare '$1Name' would refer to a group called '1Name' so references by number are delimited.
+		replacementName: groupReferenceRE.ReplaceAllString(replacementName, "$${$1$2}"),
+	}
+
+	var err error
+	if regex {
+		if p.re, err = regexp.Compile(name); err != nil {
+			return namePattern{}, fmt.Errorf("%q is not a valid regular expression: %v", name, err)
+		}
+	} else if p.re, err = globRegexp(name); err != nil {
+		return namePattern{}, err
+	}
+
+	groups := map[string]bool{}
+	for idx, group := range p.re.SubexpNames() {
+		groups[strconv.Itoa(idx)] = true
+		if group != "" {
+			groups[group] = true
+		}
+	}
+	for _, m := range groupReferenceRE.FindAllStringSubmatch(p.replacementName, -1) {
+		if !groups[m[2]] {
+			return namePattern{}, fmt.Errorf("replacement %q refers to group %q which is not captured by %q", replacementName, m[2], name)
+		}
+	}
+	return p, nil
+}
+
+// globRegexp converts a glob into an anchored regular expression in which every wildcard is a capture
+// group.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("%q is a malformed glob", glob)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for idx := 0; idx < len(glob); idx++ {
+		switch glob[idx] {
+		case '*':
+			expr.WriteString("(.*)")
+		case '?':
+			expr.WriteString("(.)")
+		case '[':
+			end := idx + 1 + strings.IndexByte(glob[idx+1:], ']')
+			expr.WriteString("(" + glob[idx:end+1] + ")")
+			idx = end
+		case '\\':
+			idx++
+			expr.WriteString(regexp.QuoteMeta(glob[idx : idx+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[idx : idx+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// target returns the target of the rule for the given name, which must be matched by the pattern.
+func (p namePattern) target(name string) target {
+	t := p.base
+	if p.replacementPkg == "" {
+		return t
+	}
+	if p.replacementName != "" {
+		name = string(p.re.ExpandString(nil, p.replacementName, name, p.re.FindStringSubmatchIndex(name)))
+	}
+	t.replacement = p.replacementPkg + "." + name
+	return t
+}
+
+func (p namePattern) String() string {
+	return p.owner + "." + p.raw
+}
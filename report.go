@@ -0,0 +1,117 @@
+package anathema
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// finding is a diagnostic together with the package or symbol that it reports and the ID of the rule
+// that forbids it, if any.
+type finding struct {
+	analysis.Diagnostic
+	subject string
+	rule    string
+
+	requires []string       // Paths of the packages that the suggested fix references.
+	rewrites *types.PkgName // Imported package of which the suggested fix removes a use, if any.
+}
+
+// reporter reports the findings of a pass unless they are suppressed by a directive or tolerated by
+// the baseline.
+type reporter struct {
+	pass       *analysis.Pass
+	files      []*ast.File
+	directives []*directive
+	allowance  *allowance
+	severities map[string]string // Overrides of the severity of rules or of other severities.
+	result     *Result
+}
+
+func newReporter(pass *analysis.Pass, files []*ast.File, allowance *allowance, severities map[string]string) *reporter {
+	r := &reporter{
+		pass:       pass,
+		files:      files,
+		allowance:  allowance,
+		severities: severities,
+		result:     &Result{Package: importerPath(pass.Pkg)},
+	}
+	for _, file := range files {
+		r.directives = append(r.directives, parseDirectives(pass.Fset, file)...)
+	}
+	return r
+}
+
+// suppressed reports whether the finding is suppressed by a directive or tolerated by the baseline.
+// It records the use of all directives that suppress the finding and adds it to the result of the pass
+// if no directive does.
+func (r *reporter) suppressed(f finding) bool {
+	var suppressed bool
+	pos := r.pass.Fset.Position(f.Pos)
+	for _, d := range r.directives {
+		if d.problem == "" && d.covers(f, pos) {
+			d.used = true
+			suppressed = true
+		}
+	}
+	if suppressed {
+		return true
+	}
+
+	fp := r.fingerprint(f)
+	r.result.Findings = append(r.result.Findings, Finding{Fingerprint: fp, Pos: f.Pos})
+	return r.allowance.tolerates(fp, pos)
+}
+
+// report reports the finding with its severity as the category of the diagnostic and the ID of its
+// rule at the end of the message. An override for the rule of the finding takes precedence over one
+// for its severity.
+func (r *reporter) report(f finding) {
+	if f.Category == "" {
+		f.Category = SeverityError
+	}
+	if severity, ok := r.severities[f.rule]; ok && f.rule != "" {
+		f.Category = severity
+	} else if severity, ok = r.severities[f.Category]; ok {
+		f.Category = severity
+	}
+	if f.rule != "" {
+		f.Message = fmt.Sprintf("%s [%s]", f.Message, f.rule)
+	}
+	r.pass.Report(f.Diagnostic)
+}
+
+func (r *reporter) fingerprint(f finding) Fingerprint {
+	fp := Fingerprint{Rule: f.rule, Package: r.result.Package, Symbol: f.subject}
+	for _, file := range r.files {
+		if file.Pos() <= f.Pos && f.Pos < file.End() {
+			fp.Function = enclosingFunction(r.pass.TypesInfo, file, f.Pos)
+			break
+		}
+	}
+	return fp
+}
+
+// parseSeverities parses a comma-separated list of severity overrides such as 'warning=error,os.Exit=info'
+// where each key is either a severity or the ID of a rule.
+func parseSeverities(spec string) (map[string]string, error) {
+	severities := map[string]string{}
+	for _, override := range strings.Split(spec, ",") {
+		if override = strings.TrimSpace(override); override == "" {
+			continue
+		}
+		idx := strings.LastIndex(override, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("severity override %q should be of the form 'key=severity'", override)
+		}
+		key, severity := strings.TrimSpace(override[:idx]), strings.TrimSpace(override[idx+1:])
+		if !validSeverity(severity) {
+			return nil, fmt.Errorf("severity override %q has an unknown severity, expected %q, %q or %q", override, SeverityError, SeverityWarning, SeverityInfo)
+		}
+		severities[key] = severity
+	}
+	return severities, nil
+}
@@ -0,0 +1,372 @@
+package anathema
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// callTemplate describes how calls to a symbol are rewritten. The call pattern binds the arguments of a
+// matched call to named placeholders which are then substituted into the replacement template.
+type callTemplate struct {
+	raw      string
+	pkg      string
+	params   []string
+	variadic bool
+	segments []templateSegment
+}
+
+type templateSegment struct {
+	text        string
+	placeholder string
+	quote       byte // Delimiter of the string literal in which the placeholder appears, if any.
+	literal     int  // Index of the literal in which the placeholder appears among those of the template.
+	format      bool // Whether the literal in which the placeholder appears is a format string.
+	standalone  bool // Whether the placeholder makes up a full operand and never needs parentheses.
+	qualifier   bool // Whether the segment references the replacement package.
+}
+
+const placeholderPrefix = "__anathema_"
+
+var placeholderRE = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+func parseCallTemplate(call string, tmpl string, pkg string, name string) (*callTemplate, error) {
+	t := &callTemplate{raw: tmpl, pkg: pkg}
+
+	expr, err := parser.ParseExpr(placeholderRE.ReplaceAllString(call, placeholderPrefix+"$1"))
+	if err != nil {
+		return nil, fmt.Errorf("call pattern %q is not a valid expression: %v", call, err)
+	}
+	ce, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, fmt.Errorf("call pattern %q is not a function call", call)
+	}
+	switch fun := ce.Fun.(type) {
+	case *ast.Ident:
+		ok = fun.Name == name
+	case *ast.SelectorExpr:
+		ok = fun.Sel.Name == name
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("call pattern %q does not call %s", call, name)
+	}
+
+	seen := map[string]bool{}
+	for _, arg := range ce.Args {
+		id, ok := arg.(*ast.Ident)
+		if !ok || !strings.HasPrefix(id.Name, placeholderPrefix) {
+			return nil, fmt.Errorf("call pattern %q may only contain placeholders as arguments", call)
+		}
+		param := strings.TrimPrefix(id.Name, placeholderPrefix)
+		if seen[param] {
+			return nil, fmt.Errorf("call pattern %q binds placeholder $%s more than once", call, param)
+		}
+		seen[param] = true
+		t.params = append(t.params, param)
+	}
+	t.variadic = ce.Ellipsis.IsValid()
+
+	qualifier, err := templateQualifier(tmpl)
+	if err != nil {
+		return nil, err
+	} else if qualifier != "" && pkg == "" {
+		return nil, fmt.Errorf("template %q references %q while the rule has no replacement package", tmpl, qualifier)
+	}
+	if t.segments, err = lexTemplate(tmpl, qualifier); err != nil {
+		return nil, err
+	}
+
+	var probe strings.Builder
+	for _, s := range t.segments {
+		switch {
+		case s.placeholder == "":
+			probe.WriteString(s.text)
+		case !seen[s.placeholder]:
+			return nil, fmt.Errorf("template %q uses placeholder $%s which is not bound by call pattern %q", tmpl, s.placeholder, call)
+		case s.quote == 0:
+			probe.WriteString(placeholderPrefix + s.placeholder)
+		}
+	}
+	expr, err = parser.ParseExpr(probe.String())
+	if err != nil {
+		return nil, fmt.Errorf("template %q is not a valid expression: %v", tmpl, err)
+	}
+	formats := formatLiterals(expr)
+	for idx := range t.segments {
+		if s := &t.segments[idx]; s.quote != 0 && s.literal < len(formats) {
+			s.format = formats[s.literal]
+		}
+	}
+	return t, nil
+}
+
+// templateQualifier returns the name by which the template references the replacement package, if it
+// does. As the template may only reference the replacement package any name will do, e.g 'yaml' for
+// gopkg.in/yaml.v3, but only a single one.
+func templateQualifier(tmpl string) (string, error) {
+	expr, err := parser.ParseExpr(placeholderRE.ReplaceAllString(tmpl, placeholderPrefix+"$1"))
+	if err != nil {
+		return "", nil // Reported once the template has been lexed.
+	}
+
+	var qualifier string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		se, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		id, ok := se.X.(*ast.Ident)
+		switch {
+		case !ok || strings.HasPrefix(id.Name, placeholderPrefix):
+		case qualifier == "":
+			qualifier = id.Name
+		case id.Name != qualifier:
+			err = fmt.Errorf("template %q references both %q and %q while it may only reference the replacement package", tmpl, qualifier, id.Name)
+		}
+		return true
+	})
+	return qualifier, err
+}
+
+// lexTemplate splits a template into literal text, placeholders and references to the replacement
+// package, keeping track of whether placeholders appear inside of string literals.
+func lexTemplate(tmpl string, qualifier string) ([]templateSegment, error) {
+	var segments []templateSegment
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			segments = append(segments, templateSegment{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	var quote byte
+	var literals int
+	for idx := 0; idx < len(tmpl); idx++ {
+		c := tmpl[idx]
+		switch {
+		case c == '$' && quote != '\'':
+			m := placeholderRE.FindStringSubmatch(tmpl[idx:])
+			if m == nil {
+				return nil, fmt.Errorf("template %q contains a '$' that does not start a placeholder", tmpl)
+			}
+			flush()
+			segments = append(segments, templateSegment{placeholder: m[1], quote: quote, literal: literals - 1})
+			idx += len(m[0]) - 1
+		case quote != 0:
+			text.WriteByte(c)
+			if c == '\\' && quote != '`' && idx+1 < len(tmpl) {
+				idx++
+				text.WriteByte(tmpl[idx])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+			literals++
+			text.WriteByte(c)
+		case isIdentifierStart(c):
+			end := idx + 1
+			for end < len(tmpl) && (isIdentifierStart(tmpl[end]) || ('0' <= tmpl[end] && tmpl[end] <= '9')) {
+				end++
+			}
+			ident := tmpl[idx:end]
+			prev := strings.TrimRight(tmpl[:idx], " \t")
+			next := strings.TrimLeft(tmpl[end:], " \t")
+			if ident == qualifier && !strings.HasSuffix(prev, ".") && strings.HasPrefix(next, ".") {
+				flush()
+				segments = append(segments, templateSegment{qualifier: true})
+				idx = len(tmpl) - len(next) // Also consume the selector's dot.
+			} else {
+				text.WriteString(ident)
+				idx = end - 1
+			}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("template %q contains an unterminated literal", tmpl)
+	}
+	flush()
+
+	for idx := range segments {
+		if segments[idx].placeholder == "" || segments[idx].quote != 0 {
+			continue
+		}
+		var prev, next string
+		if idx > 0 {
+			prev = strings.TrimRight(segments[idx-1].text, " \t")
+		}
+		if idx < len(segments)-1 {
+			next = strings.TrimLeft(segments[idx+1].text, " \t")
+		}
+		segments[idx].standalone = (prev == "" || strings.ContainsAny(prev[len(prev)-1:], "(,{[:=")) &&
+			(next == "" || strings.ContainsAny(next[:1], "),}]"))
+	}
+	return segments, nil
+}
+
+// formatLiterals reports, for each of the literals of the given expression in order, whether it is the
+// argument of a call to a function that follows the naming convention of formatting functions such as
+// fmt.Errorf.
+func formatLiterals(expr ast.Expr) []bool {
+	args := map[ast.Expr]bool{}
+	var formats []bool
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			var name string
+			switch fun := n.Fun.(type) {
+			case *ast.Ident:
+				name = fun.Name
+			case *ast.SelectorExpr:
+				name = fun.Sel.Name
+			}
+			for _, arg := range n.Args {
+				args[arg] = strings.HasSuffix(name, "f")
+			}
+		case *ast.BasicLit:
+			if n.Kind == token.STRING || n.Kind == token.CHAR {
+				formats = append(formats, args[n])
+			}
+		}
+		return true
+	})
+	return formats
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// render returns the text that replaces the given call. The qualifier function is only invoked if the
+// call matches the shape of the template and provides the local name of the replacement package.
+func (t *callTemplate) render(fset *token.FileSet, call *ast.CallExpr, qualifier func() string) (string, bool) {
+	bound := map[string][]ast.Expr{}
+	switch {
+	case t.variadic && len(call.Args) >= len(t.params)-1:
+		last := len(t.params) - 1
+		for idx := 0; idx < last; idx++ {
+			bound[t.params[idx]] = call.Args[idx : idx+1]
+		}
+		bound[t.params[last]] = call.Args[last:]
+	case !t.variadic && !call.Ellipsis.IsValid() && len(call.Args) == len(t.params):
+		for idx, param := range t.params {
+			bound[param] = call.Args[idx : idx+1]
+		}
+	default:
+		return "", false
+	}
+
+	for _, s := range t.segments {
+		if s.placeholder == "" || s.quote == 0 {
+			continue
+		}
+		if args := bound[s.placeholder]; len(args) != 1 || !isStringLiteral(args[0]) {
+			return "", false
+		} else if s.quote == '`' && strings.ContainsAny(args[0].(*ast.BasicLit).Value, "`\r") {
+			return "", false
+		}
+	}
+
+	var out strings.Builder
+	for _, s := range t.segments {
+		switch {
+		case s.qualifier:
+			if name := qualifier(); name != "" {
+				out.WriteString(name + ".")
+			}
+		case s.placeholder == "":
+			out.WriteString(s.text)
+		case s.quote != 0:
+			out.WriteString(spliceStringLiteral(bound[s.placeholder][0].(*ast.BasicLit), s.quote, s.format))
+		default:
+			args := bound[s.placeholder]
+			var texts []string
+			for _, arg := range args {
+				texts = append(texts, exprText(fset, arg, s.standalone || len(args) > 1))
+			}
+			out.WriteString(strings.Join(texts, ", "))
+			if t.variadic && s.placeholder == t.params[len(t.params)-1] && call.Ellipsis.IsValid() {
+				out.WriteString("...")
+			}
+		}
+	}
+	return out.String(), true
+}
+
+func isStringLiteral(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING
+}
+
+// spliceStringLiteral returns the content of the given string literal in a form that can be inserted
+// into a string literal delimited by the given quote. When inserted into a format string any '%' of the
+// content is escaped so that it is printed as is instead of starting a verb.
+func spliceStringLiteral(lit *ast.BasicLit, quote byte, format bool) string {
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		value = lit.Value[1 : len(lit.Value)-1]
+	}
+	if format {
+		value = strings.ReplaceAll(value, "%", "%%")
+	} else if quote == '"' && lit.Value[0] == '"' {
+		return lit.Value[1 : len(lit.Value)-1]
+	}
+	if quote == '`' {
+		return value
+	}
+	quoted := strconv.Quote(value)
+	return quoted[1 : len(quoted)-1]
+}
+
+func exprText(fset *token.FileSet, expr ast.Expr, standalone bool) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, expr); err != nil {
+		return ""
+	}
+	switch expr.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.CompositeLit, *ast.ParenExpr, *ast.SelectorExpr, *ast.IndexExpr,
+		*ast.SliceExpr, *ast.TypeAssertExpr, *ast.CallExpr:
+		return buf.String()
+	}
+	if standalone {
+		return buf.String()
+	}
+	return "(" + buf.String() + ")"
+}
+
+// The message of a rule explains why it exists. It may reference the forbidden symbol or package, its
+// replacement and the analysed package with the '$symbol', '$replacement' and '$importer' placeholders.
+var messagePlaceholders = map[string]bool{"symbol": true, "replacement": true, "importer": true}
+
+func validateMessage(msg string) error {
+	for _, m := range placeholderRE.FindAllStringSubmatch(msg, -1) {
+		if !messagePlaceholders[m[1]] {
+			return fmt.Errorf("message %q contains unknown placeholder %q, expected one of $symbol, $replacement or $importer", msg, m[0])
+		}
+	}
+	return nil
+}
+
+func expandMessage(msg string, symbol string, replacement string, importer string) string {
+	return placeholderRE.ReplaceAllStringFunc(msg, func(placeholder string) string {
+		switch placeholder[1:] {
+		case "symbol":
+			return symbol
+		case "replacement":
+			return replacement
+		default:
+			return importer
+		}
+	})
+}
@@ -0,0 +1,3 @@
+module example.com/root // Trailing.
+
+go 1.14
@@ -0,0 +1,4 @@
+// Comment.
+module "example.com/root/sub"
+
+go 1.14
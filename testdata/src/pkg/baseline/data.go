@@ -0,0 +1,27 @@
+package baseline
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // Check that findings outside of functions are tolerated.
+	"pkg/internal/helpers"
+)
+
+var _ = forbidden.Deprecated
+
+var _ = helpers.Variable // want `pkg/internal/helpers.Variable should not be used`
+
+type T struct{}
+
+func (T) exit() {
+	// Check that findings are tolerated up to the number of findings in the baseline.
+	os.Exit(1)
+	os.Exit(2)
+	os.Exit(3) // want `os.Exit should not be used`
+}
+
+func exit() {
+	// Check that findings in other functions are not tolerated.
+	os.Exit(1) // want `os.Exit should not be used`
+	_ = helpers.Variable
+}
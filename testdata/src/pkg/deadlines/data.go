@@ -0,0 +1,17 @@
+package deadlines
+
+import (
+	"os"
+
+	"pkg/internal/helpers"
+	_ "pkg/internal/old" // want `pkg/internal/old will be forbidden from 2027-01-01; replace with pkg/internal/new`
+)
+
+var (
+	_ = helpers.Constant // Check that disabled rules are not reported.
+	_ = helpers.Variable // want `pkg/internal/helpers.Variable will be forbidden from 2027-01-01`
+)
+
+func exit() {
+	os.Exit(1) // want `os.Exit should not be used`
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" //anathema:ignore pkg/internal/forbidden -- Kept until the migration is done.
+	"pkg/internal/helpers"
+)
+
+//anathema:package-ignore pkg/internal/helpers.FuncFactory -- Factories are permitted in this package.
+
+var (
+	_ = forbidden.Deprecated
+
+	// Check that trailing directives only apply to their own line.
+	_ = helpers.Variable //anathema:ignore pkg/internal/helpers.Variable -- Needed for the test.
+	_ = helpers.Variable // want `pkg/internal/helpers.Variable should not be used`
+
+	// Check that directives on a line of their own apply to the next statement or declaration.
+	//anathema:ignore pkg/internal/helpers.Constant,pkg/internal/helpers.Variable -- Needed for the test.
+	_, _ = helpers.Constant, helpers.Variable
+	_    = helpers.Constant // want `pkg/internal/helpers.Constant should not be used`
+)
+
+// Check that directives in doc comments apply to the entire declaration.
+//
+//anathema:ignore os.Exit -- Exiting is the purpose of this function.
+func exit() {
+	os.Exit(1)
+	os.Exit(2)
+}
+
+func main() {
+	// Check that directives only suppress the listed packages and symbols.
+	//anathema:ignore os.Getenv -- Wrong symbol. // want `anathema:ignore directive does not suppress any finding`
+	os.Exit(1) // want `os.Exit should not be used`
+
+	// Check that directives without a reason are reported but still suppress findings.
+	os.Exit(2) //anathema:ignore os.Exit // want `anathema:ignore directive should explain why it is needed`
+
+	// Check that directives without any packages or symbols are reported and do not suppress findings.
+	//anathema:ignore -- No symbol. // want `anathema:ignore directive should list the packages or symbols that it suppresses`
+	os.Exit(3) // want `os.Exit should not be used`
+
+	// Check that nolint directives are understood.
+	os.Exit(4) //nolint:anathema // Needed for the test.
+	os.Exit(5) //nolint
+	os.Exit(6) //nolint:errcheck,anathema // Needed for the test.
+	os.Exit(7) //nolint:errcheck // want `os.Exit should not be used`
+	os.Exit(8) /* want `nolint directive should explain why it is needed` */ //nolint:anathema
+
+	// Check that unused nolint directives are only reported if they solely target this analyzer.
+	_ = 0 //nolint:anathema // Not needed. // want `nolint directive does not suppress any finding`
+	_ = 0 //nolint:errcheck,anathema // Needed by another linter.
+
+	_ = helpers.FuncFactory()
+}
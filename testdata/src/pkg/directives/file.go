@@ -0,0 +1,16 @@
+//anathema:file-ignore pkg/internal/helpers.Variable -- Needed for the test.
+
+package main
+
+import (
+	"pkg/internal/helpers"
+)
+
+// Check that file directives apply to the entire file.
+var (
+	_ = helpers.Variable
+	_ = helpers.Variable
+)
+
+// Check that package directives apply to all files.
+var _ = helpers.FuncFactory()
@@ -0,0 +1,3 @@
+package main
+
+//anathema:file-ignore pkg/internal/helpers.Constant -- Not needed. // want `anathema:file-ignore directive does not suppress any finding`
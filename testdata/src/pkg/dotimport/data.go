@@ -0,0 +1,33 @@
+package main
+
+import (
+	. "pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+	. "pkg/internal/helpers"
+	. "pkg/internal/old" // want `pkg/internal/old should be replaced with pkg/internal/new`
+)
+
+// Forbidden symbols.
+var (
+	// Check that symbols of forbidden packages are picked up.
+	_ = Deprecated   // want `pkg/internal/forbidden.Deprecated should not be used`
+	_ = Background() // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
+
+	// Check that forbidden symbols are picked up.
+	_ = Variable // want `pkg/internal/helpers.Variable should not be used`
+	_ = Constant // want `pkg/internal/helpers.Constant should be replaced with pkg/internal/helpers.Variable`
+
+	// Check that forbidden symbols are picked up when one of their fields is selected, without mistaking
+	// the field for a symbol of the same name.
+	_ = Struct.StructFactory // want `pkg/internal/helpers.Struct should not be used`
+)
+
+// Permitted symbols.
+var (
+	_ = FuncFactory
+)
+
+func main() {
+	// Check that local declarations are not mistaken for dot-imported symbols.
+	Variable := ""
+	_ = Variable
+}
@@ -0,0 +1,19 @@
+package effects
+
+import (
+	"pkg/internal/helpers"
+	_ "pkg/internal/new" // want `pkg/internal/new should not be used`
+	old_context "pkg/internal/old"
+)
+
+// Forbidden symbols.
+var (
+	_ old_context.Context // want `pkg/internal/old.Context should not be used`
+	_ = helpers.Variable  // want `pkg/internal/helpers.Variable should not be used`
+)
+
+// Permitted symbols.
+var (
+	_ = old_context.Background()
+	_ = helpers.Constant
+)
@@ -0,0 +1,17 @@
+package hierarchy
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+	"pkg/internal/helpers"
+)
+
+var (
+	_ = forbidden.Deprecated
+	_ = helpers.Variable // Check that the rules of nested configurations do not apply to parents.
+)
+
+func exit() {
+	os.Exit(1) // want `os.Exit should not be used \[no-exit\]`
+}
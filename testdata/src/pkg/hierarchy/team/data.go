@@ -0,0 +1,17 @@
+package team
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+	"pkg/internal/helpers"
+)
+
+var (
+	_ = forbidden.Deprecated
+	_ = helpers.Variable // want `pkg/internal/helpers.Variable should not be used`
+)
+
+func exit() {
+	os.Exit(1) // want `pkg/hierarchy/team should return an error instead of calling os.Exit \[no-exit\]`
+}
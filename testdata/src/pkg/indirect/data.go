@@ -0,0 +1,55 @@
+package main
+
+import "pkg/internal/helpers"
+
+type alias = helpers.StructType // want `pkg/internal/helpers.StructType should not be used`
+
+type holder struct {
+	// Check that fields are picked up when their declaration does not name the forbidden type.
+	value alias // want `field value of type pkg/internal/helpers.StructType should not be used`
+
+	// Check that fields that name the forbidden type are not reported twice.
+	other []helpers.StructType // want `pkg/internal/helpers.StructType should not be used`
+}
+
+// Check that recursive types of the package are handled.
+type list struct {
+	next  *list   // want `field next of type \*pkg/indirect.list, which contains pkg/internal/helpers.StructType, should not be used`
+	value *holder // want `field value of type \*pkg/indirect.holder, which contains pkg/internal/helpers.StructType, should not be used`
+}
+
+func main() {
+	// Check that values are picked up.
+	_ = helpers.NewStructType() // want `value of type pkg/internal/helpers.StructType should not be used`
+
+	// Check that inferred variables are picked up without reporting the values that they are assigned.
+	v := helpers.NewStructType()            // want `variable v of type pkg/internal/helpers.StructType should not be used`
+	for _, s := range helpers.StructTypes { // want `variable s of type pkg/internal/helpers.StructType should not be used`
+		_ = s
+	}
+	var w = (helpers.NewStructType()) // want `variable w of type pkg/internal/helpers.StructType should not be used`
+	_ = w
+
+	// Check that function values are picked up but that calls are only reported once.
+	f := helpers.NewStructType // want `variable f of type func\(\) pkg/internal/helpers.StructType should not be used`
+	_ = f()                    // want `value of type pkg/internal/helpers.StructType should not be used`
+
+	// Check that variables of the types of the package that contain the forbidden type are picked up.
+	var h holder // want `variable h of type pkg/indirect.holder, which contains pkg/internal/helpers.StructType, should not be used`
+	_ = h
+	_ = holder{} // want `value of type pkg/indirect.holder, which contains pkg/internal/helpers.StructType, should not be used`
+	var l list   // want `variable l of type pkg/indirect.list, which contains pkg/internal/helpers.StructType, should not be used`
+	_ = l
+
+	// Check that fields of types that are only indirectly forbidden are picked up.
+	_ = holder{}.value // want `value of type pkg/internal/helpers.StructType should not be used` `value of type pkg/indirect.holder, which contains pkg/internal/helpers.StructType, should not be used`
+
+	// Check that conversions and composite literals that name the forbidden type are not reported twice.
+	_ = helpers.StructType(v)  // want `pkg/internal/helpers.StructType should not be used`
+	_ = []helpers.StructType{} // want `pkg/internal/helpers.StructType should not be used`
+}
+
+// Permitted symbols.
+var (
+	_ = helpers.Variable
+)
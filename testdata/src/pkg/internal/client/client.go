@@ -0,0 +1,21 @@
+package client
+
+type Client struct {
+	Deadline int
+	Timeout  int
+}
+
+func (c *Client) Do() {}
+
+func (c Client) Get() {}
+
+type Doer interface {
+	Do()
+}
+
+type ReadDoer interface {
+	Doer
+	Read()
+}
+
+var DefaultClient = &Client{}
@@ -0,0 +1,9 @@
+package errors
+
+func New(msg string) error {
+	return nil
+}
+
+func Wrap(err error, msg string) error {
+	return err
+}
@@ -18,3 +18,9 @@ var Struct = struct{ StructFactory string }{}
 type StructType struct{}
 
 type InterfaceType interface{}
+
+func NewStructType() StructType {
+	return StructType{}
+}
+
+var StructTypes []StructType
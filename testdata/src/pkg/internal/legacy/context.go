@@ -0,0 +1,7 @@
+package legacy
+
+type Context struct{}
+
+func Background() Context {
+	return Context{}
+}
@@ -0,0 +1,17 @@
+package messages
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // want `pkg/internal/forbidden is unmaintained \(see https://example\.com/migrations/forbidden\)`
+	"pkg/internal/helpers"
+)
+
+var (
+	_ = forbidden.Deprecated
+	_ = helpers.Variable // want `use pkg/internal/helpers\.Constant instead of pkg/internal/helpers\.Variable \(forbidden from 2027-01-01\)`
+)
+
+func exit() {
+	os.Exit(1) // want `pkg/messages should return errors instead of calling os\.Exit`
+}
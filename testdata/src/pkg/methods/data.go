@@ -0,0 +1,40 @@
+package main
+
+import "pkg/internal/client"
+
+type wrapper struct {
+	*client.Client
+}
+
+// Forbidden methods and fields.
+func main() {
+	c := &client.Client{}
+
+	// Check that method calls and method values are picked up.
+	c.Do()   // want `\(pkg/internal/client.Client\).Do should not be used`
+	_ = c.Do // want `\(pkg/internal/client.Client\).Do should not be used`
+
+	// Check that method expressions are picked up.
+	_ = (*client.Client).Do // want `\(pkg/internal/client.Client\).Do should not be used`
+
+	// Check that promoted methods and fields are picked up.
+	w := wrapper{c}
+	w.Do()        // want `\(pkg/internal/client.Client\).Do should not be used`
+	_ = w.Timeout // want `\(pkg/internal/client.Client\).Timeout should be replaced with \(pkg/internal/client.Client\).Deadline`
+
+	// Check that calls through interfaces, including embedded ones, are picked up.
+	var d client.Doer = c
+	d.Do() // want `\(pkg/internal/client.Doer\).Do should not be used`
+	var rd client.ReadDoer
+	rd.Do() // want `\(pkg/internal/client.Doer\).Do should not be used`
+
+	// Check that fields of package-level variables are picked up.
+	_ = client.DefaultClient.Timeout // want `\(pkg/internal/client.Client\).Timeout should be replaced with \(pkg/internal/client.Client\).Deadline`
+}
+
+// Permitted methods and fields.
+func permitted(c client.Client, rd client.ReadDoer) {
+	c.Get()
+	rd.Read()
+	_ = c.Deadline
+}
@@ -0,0 +1,6 @@
+package main
+
+import "pkg/internal/legacy" // want `pkg/internal/legacy should be replaced with pkg/internal/new`
+
+// Check that the original local name is retained through an alias.
+var _ legacy.Context = legacy.Background()
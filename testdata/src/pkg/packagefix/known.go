@@ -0,0 +1,6 @@
+package main
+
+import "pkg/internal/newlog"
+
+// Ensure that the name of the replacement package is known to the analysis.
+var _ = logging.Print
@@ -0,0 +1,6 @@
+package main
+
+import log "pkg/internal/oldlog" // want `pkg/internal/oldlog should be replaced with pkg/internal/newlog`
+
+// Check that existing aliases are retained.
+var _ = log.Print
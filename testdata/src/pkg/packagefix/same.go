@@ -0,0 +1,6 @@
+package main
+
+import "pkg/internal/old" // want `pkg/internal/old should be replaced with pkg/internal/new`
+
+// Check that only the path is replaced when both packages have the same name.
+var _ context.Context = context.Background()
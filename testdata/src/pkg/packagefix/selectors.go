@@ -0,0 +1,9 @@
+package main
+
+import "pkg/internal/oldlog" // want `pkg/internal/oldlog should be replaced with pkg/internal/newlog`
+
+// Check that all selectors are updated to the name of the replacement package.
+func main() {
+	oldlog.Print("foo")
+	oldlog.Print("bar")
+}
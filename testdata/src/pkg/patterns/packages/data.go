@@ -0,0 +1,13 @@
+package packages
+
+import (
+	// Check that subtree patterns match all packages below them.
+	_ "pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+
+	// Check that subtree replacements map packages onto the equivalent package of the replacement.
+	_ "pkg/internal/nested/deeper" // want `pkg/internal/nested/deeper should be replaced with pkg/internal/v2/nested/deeper`
+
+	// Check that globs take precedence over subtrees and exact paths over globs.
+	_ "pkg/internal/old"    // want `pkg/internal/old should be replaced with pkg/internal/new`
+	_ "pkg/internal/oldlog" // want `pkg/internal/oldlog should be replaced with pkg/internal/newlog`
+)
@@ -0,0 +1,19 @@
+package symbols
+
+import (
+	"pkg/internal/forbidden"
+	"pkg/internal/helpers"
+	"pkg/internal/nested/deeper"
+)
+
+var (
+	// Check that patterns only match the symbols named in their rules.
+	_ = helpers.Constant
+
+	// Check that globs take precedence over subtrees and exact paths over globs.
+	_ = forbidden.Deprecated // want `pkg/internal/forbidden.Deprecated should be replaced with pkg/internal/forbidden.Replacement`
+	_ = helpers.Variable     // want `pkg/internal/helpers.Variable should be replaced with pkg/internal/helpers.Constant`
+
+	// Check that subtree replacements map symbols onto the equivalent package of the replacement.
+	_ = deeper.Variable // want `pkg/internal/nested/deeper.Variable should be replaced with pkg/internal/v2/nested/deeper.Variable`
+)
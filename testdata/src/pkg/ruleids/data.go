@@ -0,0 +1,19 @@
+package ruleids
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used \[no-forbidden\]`
+	"pkg/internal/helpers"
+)
+
+var (
+	_ = forbidden.Deprecated
+	_ = helpers.Constant // Check that rules that are not enabled are not applied.
+	_ = helpers.Variable // want `pkg/internal/helpers.Variable should be replaced with pkg/internal/helpers.Constant \[migrate-helpers\]`
+	_ = helpers.Variable //anathema:ignore migrate-helpers -- Check that directives accept rule IDs.
+)
+
+func exit() {
+	os.Exit(1) // Check that disabled rules are not applied.
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt" // want `fmt should not be used`
+
+	"pkg/internal/helpers"
+	new_context "pkg/internal/new"
+	old_context "pkg/internal/old" // want `pkg/internal/old should not be used`
+)
+
+// Forbidden symbols.
+var (
+	_ new_context.Context // want `pkg/internal/new.Context should not be used`
+)
+
+func main() {
+	fmt.Print("foo")
+}
+
+// Permitted symbols.
+var (
+	_ = helpers.Variable
+	_ = old_context.Background()
+	_ = new_context.Background()
+)
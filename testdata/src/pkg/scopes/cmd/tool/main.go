@@ -0,0 +1,10 @@
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	// Check that symbols are permitted within the importers in which they are allowed.
+	os.Exit(0)
+}
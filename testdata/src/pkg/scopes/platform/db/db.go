@@ -0,0 +1,19 @@
+package db
+
+import (
+	// Check that packages are permitted within the importers in which they are allowed.
+	"database/sql"
+	"os"
+
+	"pkg/internal/helpers"
+)
+
+var _ *sql.DB
+
+func Exit() {
+	// Check that symbols are forbidden outside of the importers in which they are allowed.
+	os.Exit(1) // want `os.Exit should not be used`
+}
+
+// Check that rules are not in effect outside of the importers to which they are restricted.
+var _ = helpers.Variable
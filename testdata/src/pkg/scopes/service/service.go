@@ -0,0 +1,13 @@
+package service
+
+import (
+	// Check that packages are forbidden outside of the importers in which they are allowed.
+	"database/sql" // want `database/sql should not be used`
+
+	"pkg/internal/helpers"
+)
+
+var _ *sql.DB
+
+// Check that rules are in effect within the importers to which they are restricted.
+var _ = helpers.Variable // want `pkg/internal/helpers.Variable should not be used`
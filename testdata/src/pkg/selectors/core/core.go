@@ -0,0 +1,15 @@
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"pkg/internal/helpers" // want `pkg/internal/helpers should not be used \[@thirdparty\]`
+	_ "pkg/internal/new"   // want `pkg/internal/new should not be used \[@module:pkg/internal/new\]`
+	"pkg/selectors/util"
+)
+
+func Print() {
+	fmt.Print(util.Value, helpers.Constant)
+	os.Exit(1) // want `os.Exit should not be used \[@std.Exit\]`
+}
@@ -0,0 +1,3 @@
+module pkg/selectors
+
+go 1.14
@@ -0,0 +1,18 @@
+package severity
+
+import (
+	"os"
+
+	"pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+	"pkg/internal/helpers"
+)
+
+var (
+	_ = forbidden.Deprecated
+	_ = helpers.Constant // want `pkg/internal/helpers.Constant should not be used`
+	_ = helpers.Variable // want `pkg/internal/helpers.Variable should not be used`
+)
+
+func exit() {
+	os.Exit(1) // want `os.Exit should not be used`
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+
+	"pkg/internal/helpers"
+	context "pkg/internal/old"
+)
+
+// Check that symbols are renamed in place when the replacement lives in the same package.
+var _ = helpers.Constant // want `pkg/internal/helpers.Constant should be replaced with pkg/internal/helpers.Variable`
+
+func main() {
+	// Check that the import of the original package is removed once it is no longer used.
+	fmt.Println(context.Background()) // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
+}
@@ -0,0 +1,9 @@
+package main
+
+import "pkg/internal/old"
+
+// Check that the original import is retained when one of its uses is suppressed.
+var (
+	_ = context.Background() // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
+	_ = context.Background() //anathema:ignore pkg/internal/old.Background -- Needed for the test.
+)
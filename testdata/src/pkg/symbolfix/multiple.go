@@ -0,0 +1,10 @@
+package main
+
+import "pkg/internal/old"
+
+// Check that the fix of each use also rewrites the other uses so that it can remove the original import,
+// the name of which is then available to the new import.
+var (
+	_ = context.Background() // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
+	_ = context.Background() // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
+)
@@ -0,0 +1,6 @@
+package main
+
+import "pkg/internal/old"
+
+// Check that the original import is retained when other symbols of the package are still in use.
+var _ context.Context = context.Background() // want `pkg/internal/old.Background should be replaced with pkg/internal/new.Background`
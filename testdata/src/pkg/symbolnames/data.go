@@ -0,0 +1,22 @@
+package symbolnames
+
+import (
+	"pkg/internal/oldlog"
+)
+
+func main() {
+	// Check that capture groups of regular expressions are substituted in the replacement.
+	oldlog.Print("message")          // want `pkg/internal/oldlog.Print should be replaced with pkg/internal/newlog.Info`
+	oldlog.Printf("message: %d", 42) // want `pkg/internal/oldlog.Printf should be replaced with pkg/internal/newlog.Infof`
+	oldlog.Fatal("message")          // want `pkg/internal/oldlog.Fatal should be replaced with pkg/internal/newlog.Error`
+	oldlog.Fatalf("message: %d", 42) // want `pkg/internal/oldlog.Fatalf should be replaced with pkg/internal/newlog.Errorf`
+
+	// Check that the wildcards of globs are substituted in the replacement.
+	oldlog.MustOpen("file") // want `pkg/internal/oldlog.MustOpen should be replaced with pkg/internal/oldlog.Open`
+
+	// Check that literal names take precedence over patterns.
+	oldlog.MustClose() // want `pkg/internal/oldlog.MustClose should not be used`
+
+	// Check that names that are not matched by any pattern are permitted.
+	_ = oldlog.Close()
+}
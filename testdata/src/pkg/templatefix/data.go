@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"pkg/internal/errors"
+)
+
+var err = errors.New("failure")
+
+var (
+	// Check that arguments are substituted, including within string literals.
+	_ = errors.Wrap(err, "context")   // want `pkg/internal/errors.Wrap should be replaced with fmt.Errorf`
+	_ = errors.Wrap(err, `"context"`) // want `pkg/internal/errors.Wrap should be replaced with fmt.Errorf`
+
+	// Check that arguments which end up in a format string are escaped.
+	_ = errors.Wrap(err, "100% done") // want `pkg/internal/errors.Wrap should be replaced with fmt.Errorf`
+
+	// Check that calls which do not match the shape of the template are still reported.
+	_ = errors.Wrap(err, fmt.Sprint("context")) // want `pkg/internal/errors.Wrap should be replaced with fmt.Errorf`
+	_ = errors.Wrap                             // want `pkg/internal/errors.Wrap should be replaced with fmt.Errorf`
+)
+
+func main() {
+	// Check that the original import is removed once it is no longer used.
+	_, _ = ioutil.ReadAll(os.Stdin) // want `io/ioutil.ReadAll should be replaced with io.ReadAll\(\$r\)`
+}
@@ -0,0 +1,3 @@
+module pkg/trusted
+
+go 1.14
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	_ "io/ioutil" // want `io/ioutil should not be used`
+
+	"pkg/internal/helpers" // want `pkg/internal/helpers should not be used`
+	new_context "pkg/internal/new"
+	"pkg/trusted/sub"
+)
+
+// Forbidden symbols.
+var (
+	_ = helpers.Variable         // want `pkg/internal/helpers.Variable should not be used`
+	_ = new_context.Background() // want `pkg/internal/new.Background should not be used`
+)
+
+func main() {
+	fmt.Print(sub.Value)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	. "pkg/internal/forbidden" // want `pkg/internal/forbidden should not be used`
+	. "pkg/internal/new"
+)
+
+// Forbidden symbols.
+var (
+	_ = Deprecated // want `pkg/internal/forbidden.Deprecated should not be used`
+	_ Context      // want `pkg/internal/new.Context should not be used`
+)
+
+// Permitted symbols.
+var (
+	_ = Background()
+)